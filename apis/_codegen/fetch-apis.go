@@ -9,6 +9,7 @@ import (
 	"log"
 	"reflect"
 	"sort"
+	"strings"
 	"sync"
 
 	got "github.com/taskcluster/go-got"
@@ -68,13 +69,17 @@ func main() {
 	urls := make(map[string]bool, 0)
 
 	// addSchema is the function that determines if a schema url needs to be
-	// fetched and starts the goroutine to fetch it if needed.
-	addSchema := func(url string) {
+	// fetched and starts the goroutine to fetch it if needed. Schemas
+	// referenced with $ref are fetched too, so they can be resolved offline.
+	var addSchema func(url string)
+	addSchema = func(url string) {
+		// map access/modification is not thread-safe.
+		mutex.Lock()
+		defer mutex.Unlock()
 		if url == "" || urls[url] {
 			return
 		}
 
-		// map access/modification is not thread-safe.
 		urls[url] = true
 		wg.Add(1)
 		go func() {
@@ -83,6 +88,9 @@ func main() {
 			mutex.Lock()
 			schemas[url] = s
 			mutex.Unlock()
+			for _, ref := range schemaReferences(s) {
+				addSchema(ref)
+			}
 			wg.Done()
 		}()
 	}
@@ -142,6 +150,34 @@ func fetchSchema(g *got.Got, url string) string {
 	return string(res.Body)
 }
 
+// schemaReferences returns the absolute schema URLs referenced with $ref in
+// the given schema, normalized to end with '#' like the entry schema URLs.
+func schemaReferences(schema string) []string {
+	var i interface{}
+	if err := json.Unmarshal([]byte(schema), &i); err != nil {
+		return nil
+	}
+	refs := []string{}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok && strings.HasPrefix(ref, "http") {
+				refs = append(refs, strings.SplitN(ref, "#", 2)[0]+"#")
+			}
+			for _, value := range v {
+				walk(value)
+			}
+		case []interface{}:
+			for _, value := range v {
+				walk(value)
+			}
+		}
+	}
+	walk(i)
+	return refs
+}
+
 // generator holds a buffer of the output that will be generated.
 type generator struct {
 	buf bytes.Buffer
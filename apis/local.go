@@ -0,0 +1,24 @@
+package apis
+
+import "github.com/taskcluster/taskcluster-cli/extpoints"
+
+// A localCommand is a subcommand of a service command which is implemented by
+// the CLI itself, rather than by calling an API end-point.
+type localCommand struct {
+	// Name of the subcommand, e.g. 'temp-creds'
+	Name string
+	// Arguments and options following the name in the usage string
+	Pattern string
+	// Title shown by 'taskcluster <service> help <name>'
+	Title string
+	// Description shown by 'taskcluster <service> help <name>'
+	Description string
+	// Options specific to the subcommand, as pairs of option and description
+	Options [][]string
+	Execute func(context extpoints.Context) bool
+}
+
+// localCommands holds the local subcommands by service command name.
+var localCommands = map[string][]localCommand{
+	"auth": {tempCredsCommand},
+}
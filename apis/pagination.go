@@ -0,0 +1,199 @@
+package apis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/taskcluster/taskcluster-cli/apis/definitions"
+	"github.com/taskcluster/taskcluster-cli/extpoints"
+)
+
+// continuationToken is the query-string parameter, or payload property, used
+// for paging.
+const continuationToken = "continuationToken"
+
+// paginated returns true, if entry takes a continuationToken query option, or
+// a continuationToken in its payload.
+func paginated(entry *definitions.Entry) bool {
+	for _, q := range entry.Query {
+		if q == continuationToken {
+			return true
+		}
+	}
+	return bodyPaginated(entry)
+}
+
+// bodyPaginated returns true, if entry takes the continuationToken in its
+// payload, as the index does.
+func bodyPaginated(entry *definitions.Entry) bool {
+	properties, _ := loadSchema(entry.Input)["properties"].(map[string]interface{})
+	_, ok := properties[continuationToken]
+	return ok
+}
+
+// setToken returns the payload input with the continuationToken set to token.
+func setToken(input []byte, token string) ([]byte, error) {
+	payload := map[string]interface{}{}
+	if len(bytes.TrimSpace(input)) > 0 {
+		if err := json.Unmarshal(input, &payload); err != nil {
+			return nil, fmt.Errorf("the payload isn't an object: %s", err)
+		}
+	}
+	payload[continuationToken] = token
+	return json.Marshal(payload)
+}
+
+// paginated returns true, if any of the entries in p supports pagination.
+func (p apiProvider) paginated() bool {
+	for _, e := range p.Entries {
+		if paginated(&e) {
+			return true
+		}
+	}
+	return false
+}
+
+// pageMerger accumulates the arrays from a sequence of paged responses.
+type pageMerger struct {
+	result map[string]interface{}
+}
+
+// add merges page into the result and returns the continuationToken for the
+// next page, or the empty string if there are no more pages.
+func (m *pageMerger) add(page map[string]interface{}) string {
+	token, _ := page[continuationToken].(string)
+	delete(page, continuationToken)
+
+	if m.result == nil {
+		m.result = page
+		return token
+	}
+	for key, value := range page {
+		items, ok := value.([]interface{})
+		if !ok {
+			continue
+		}
+		if existing, ok := m.result[key].([]interface{}); ok {
+			m.result[key] = append(existing, items...)
+		} else {
+			m.result[key] = items
+		}
+	}
+	return token
+}
+
+// pageItems returns all items from the arrays in page, ordered by key.
+func pageItems(page map[string]interface{}) []interface{} {
+	keys := []string{}
+	for key, value := range page {
+		if _, ok := value.([]interface{}); ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	items := []interface{}{}
+	for _, key := range keys {
+		items = append(items, page[key].([]interface{})...)
+	}
+	return items
+}
+
+// executeAll calls entry repeatedly following the continuationToken, in the
+// query-string or payload, until all pages have been fetched. Pages are either
+// merged into a single JSON document, or streamed item by item as
+// newline-delimited JSON.
+func (p apiProvider) executeAll(
+	baseURL string, entry *definitions.Entry, context extpoints.Context,
+	args, query map[string]string, payload io.Reader, output io.Writer,
+	ndjson bool,
+) bool {
+	var input []byte
+	// Read all input
+	if entry.Input != "" {
+		data, err := ioutil.ReadAll(payload)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read input, error: %s\n", err)
+			return false
+		}
+		input = data
+	}
+
+	validate := validateOutputEnabled(context)
+	valid := true
+
+	merger := pageMerger{}
+	for {
+		res, err := p.request(baseURL, entry, context, args, query, input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Request failed, error: %s\n", err)
+			return false
+		}
+		data, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read response, error: %s\n", err)
+			return false
+		}
+
+		// Print the error response as is, if the request wasn't successful
+		if res.StatusCode/100 != 2 {
+			output.Write(data)
+			return false
+		}
+
+		var page map[string]interface{}
+		if err = json.Unmarshal(data, &page); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to parse response, error: %s\n", err)
+			return false
+		}
+
+		var token string
+		if ndjson {
+			if validate && !validateOutput(entry, data) {
+				valid = false
+			}
+			token, _ = page[continuationToken].(string)
+			for _, item := range pageItems(page) {
+				line, _ := json.Marshal(item)
+				if err = writeResult(context, append(line, '\n'), output); err != nil {
+					fmt.Fprintf(os.Stderr, "Error writing result, error: %s\n", err)
+					return false
+				}
+			}
+		} else {
+			token = merger.add(page)
+		}
+
+		if token == "" {
+			break
+		}
+		if !bodyPaginated(entry) {
+			query[continuationToken] = token
+		} else if input, err = setToken(input, token); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to construct payload, error: %s\n", err)
+			return false
+		}
+	}
+
+	if !ndjson {
+		data, _ := json.MarshalIndent(merger.result, "", "  ")
+		if validate && !validateOutput(entry, data) {
+			valid = false
+		}
+		if err := writeResult(context, append(data, '\n'), output); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing result, error: %s\n", err)
+			return false
+		}
+	}
+
+	if !valid {
+		return context.Fail(exitSchemaDrift)
+	}
+	return true
+}
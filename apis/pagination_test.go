@@ -0,0 +1,75 @@
+package apis
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster-cli/apis/definitions"
+)
+
+func TestPaginated(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(paginated(&definitions.Entry{
+		Query: []string{"continuationToken", "limit"},
+	}))
+	assert.False(paginated(&definitions.Entry{
+		Query: []string{"prefix"},
+	}))
+
+	// The index takes the continuationToken in the payload
+	assert.True(paginated(&definitions.Entry{
+		Input: "http://schemas.taskcluster.net/index/v1/list-tasks-request.json#",
+	}))
+	assert.False(bodyPaginated(&definitions.Entry{
+		Input: "http://schemas.taskcluster.net/queue/v1/create-task-request.json#",
+	}))
+}
+
+func TestSetToken(t *testing.T) {
+	assert := assert.New(t)
+
+	data, err := setToken(nil, "next")
+	assert.NoError(err)
+	assert.JSONEq(`{"continuationToken": "next"}`, string(data))
+
+	data, err = setToken([]byte(`{"limit": 10, "continuationToken": "first"}`), "next")
+	assert.NoError(err)
+	assert.JSONEq(`{"limit": 10, "continuationToken": "next"}`, string(data))
+
+	_, err = setToken([]byte(`[]`), "next")
+	assert.Error(err)
+}
+
+func TestPageMerger(t *testing.T) {
+	assert := assert.New(t)
+
+	m := pageMerger{}
+	token := m.add(map[string]interface{}{
+		"taskGroupId":       "abc",
+		"tasks":             []interface{}{"a", "b"},
+		"continuationToken": "next",
+	})
+	assert.Equal("next", token)
+
+	token = m.add(map[string]interface{}{
+		"taskGroupId": "abc",
+		"tasks":       []interface{}{"c"},
+	})
+	assert.Equal("", token)
+
+	assert.Equal(map[string]interface{}{
+		"taskGroupId": "abc",
+		"tasks":       []interface{}{"a", "b", "c"},
+	}, m.result)
+}
+
+func TestPageItems(t *testing.T) {
+	assert := assert.New(t)
+
+	items := pageItems(map[string]interface{}{
+		"taskId":    "abc",
+		"artifacts": []interface{}{"x", "y"},
+	})
+	assert.Equal([]interface{}{"x", "y"}, items)
+}
@@ -0,0 +1,434 @@
+package apis
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/taskcluster/taskcluster-cli/apis/definitions"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// pathSegment is a single step in a payload path, either an object key or
+// an array index. An index of -1 means append to the array.
+type pathSegment struct {
+	key   string
+	index int
+	isKey bool
+}
+
+// parsePath parses paths on the form 'a.b[0].c' or 'a.b[]', where '[]'
+// appends to an array.
+func parsePath(path string) ([]pathSegment, error) {
+	segments := []pathSegment{}
+	key := ""
+	hasKey := false
+	flush := func() {
+		if hasKey {
+			segments = append(segments, pathSegment{key: key, isKey: true})
+		}
+		key = ""
+		hasKey = false
+	}
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '.':
+			if !hasKey && (len(segments) == 0 || segments[len(segments)-1].isKey) {
+				return nil, fmt.Errorf("empty key in path '%s'", path)
+			}
+			flush()
+		case '[':
+			flush()
+			end := strings.IndexByte(path[i:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated '[' in path '%s'", path)
+			}
+			index := -1
+			if s := path[i+1 : i+end]; s != "" {
+				n, err := strconv.Atoi(s)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("invalid index '%s' in path '%s'", s, path)
+				}
+				index = n
+			}
+			segments = append(segments, pathSegment{index: index})
+			i += end
+		default:
+			key += string(c)
+			hasKey = true
+		}
+	}
+	flush()
+	if len(segments) == 0 {
+		return nil, errors.New("empty path")
+	}
+	return segments, nil
+}
+
+// setPath sets value at path within doc, creating objects and arrays as
+// needed, and returns the updated document.
+func setPath(doc interface{}, path []pathSegment, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	segment := path[0]
+
+	if segment.isKey {
+		obj, ok := doc.(map[string]interface{})
+		if doc == nil {
+			obj = make(map[string]interface{})
+		} else if !ok {
+			return nil, fmt.Errorf("cannot set key '%s' on a non-object", segment.key)
+		}
+		v, err := setPath(obj[segment.key], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		obj[segment.key] = v
+		return obj, nil
+	}
+
+	list, ok := doc.([]interface{})
+	if doc != nil && !ok {
+		return nil, fmt.Errorf("cannot set index on a non-array")
+	}
+	index := segment.index
+	if index == -1 {
+		index = len(list)
+	}
+	if index > len(list) {
+		return nil, fmt.Errorf("index %d is out of range for array of length %d", index, len(list))
+	}
+	if index == len(list) {
+		list = append(list, nil)
+	}
+	v, err := setPath(list[index], path[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	list[index] = v
+	return list, nil
+}
+
+// loadSchema returns the decoded JSON schema for url, or nil if unknown.
+func loadSchema(url string) map[string]interface{} {
+	if url == "" {
+		return nil
+	}
+	document, err := schemaLoader{source: url}.LoadJSON()
+	if err != nil {
+		return nil
+	}
+	schema, _ := document.(map[string]interface{})
+	return schema
+}
+
+// subSchema returns the schema describing the value at path within a value
+// described by schema, or nil if it cannot be determined.
+func subSchema(schema map[string]interface{}, path []pathSegment) map[string]interface{} {
+	for _, segment := range path {
+		if schema == nil {
+			return nil
+		}
+		if ref, ok := schema["$ref"].(string); ok {
+			schema = loadSchema(ref)
+			if schema == nil {
+				return nil
+			}
+		}
+		if segment.isKey {
+			properties, _ := schema["properties"].(map[string]interface{})
+			if s, ok := properties[segment.key].(map[string]interface{}); ok {
+				schema = s
+			} else {
+				schema, _ = schema["additionalProperties"].(map[string]interface{})
+			}
+		} else {
+			schema, _ = schema["items"].(map[string]interface{})
+		}
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		schema = loadSchema(ref)
+	}
+	return schema
+}
+
+// schemaTypes returns the list of types allowed by schema.
+func schemaTypes(schema map[string]interface{}) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := []string{}
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// coerce converts raw into a value of the type allowed by schema. Values on
+// the form '@file.json' or '@file.yaml' are loaded from file. If the type is
+// unknown, raw is parsed as JSON, falling back to a plain string.
+func coerce(schema map[string]interface{}, raw string) (interface{}, error) {
+	if strings.HasPrefix(raw, "@") {
+		return loadFile(raw[1:])
+	}
+
+	types := schemaTypes(schema)
+	if len(types) == 0 {
+		var value interface{}
+		if err := json.Unmarshal([]byte(raw), &value); err == nil {
+			return value, nil
+		}
+		return raw, nil
+	}
+
+	var err error
+	for _, t := range types {
+		switch t {
+		case "string":
+			return raw, nil
+		case "integer":
+			var n int64
+			if n, err = strconv.ParseInt(raw, 10, 64); err == nil {
+				return n, nil
+			}
+		case "number":
+			var f float64
+			if f, err = strconv.ParseFloat(raw, 64); err == nil {
+				return f, nil
+			}
+		case "boolean":
+			var b bool
+			if b, err = strconv.ParseBool(raw); err == nil {
+				return b, nil
+			}
+		case "null":
+			if raw == "null" {
+				return nil, nil
+			}
+			err = errors.New("must be null")
+		case "array", "object":
+			var value interface{}
+			if err = json.Unmarshal([]byte(raw), &value); err == nil {
+				return value, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("'%s' is not a valid %s", raw, strings.Join(types, " or "))
+}
+
+// loadFile reads a JSON or YAML document from file, YAML is assumed if the
+// file has a .yml or .yaml extension.
+func loadFile(file string) (interface{}, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(file))
+	if ext == ".yml" || ext == ".yaml" {
+		return ParseYAML(data)
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("failed to parse '%s' as JSON, error: %s", file, err)
+	}
+	return value, nil
+}
+
+// ParseYAML parses a YAML document into values compatible with encoding/json.
+func ParseYAML(data []byte) (interface{}, error) {
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML, error: %s", err)
+	}
+	return convertYAML(value), nil
+}
+
+// convertYAML replaces the map[interface{}]interface{} values produced by the
+// yaml package with map[string]interface{}, so they can be marshalled as JSON.
+func convertYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[fmt.Sprint(key)] = convertYAML(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range v {
+			v[i] = convertYAML(val)
+		}
+		return v
+	}
+	return value
+}
+
+// buildPayload applies a list of 'path=value' assignments on top of base,
+// using schema to coerce values to the expected types.
+func buildPayload(schema map[string]interface{}, base interface{}, assignments []string) (interface{}, error) {
+	doc := base
+	for _, assignment := range assignments {
+		parts := strings.SplitN(assignment, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid assignment '%s', must be on the form path=value", assignment)
+		}
+		path, err := parsePath(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		value, err := coerce(subSchema(schema, path), parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for '%s': %s", parts[0], err)
+		}
+		if doc, err = setPath(doc, path, value); err != nil {
+			return nil, fmt.Errorf("cannot set '%s': %s", parts[0], err)
+		}
+	}
+	return doc, nil
+}
+
+// BuildInput applies a list of 'path=value' assignments on top of base, using
+// the input schema of an API end-point to coerce values to the expected
+// types. The end-point is given by the name of the service command and
+// method, e.g. 'queue' and 'createTask'.
+func BuildInput(service, method string, base interface{}, assignments []string) (interface{}, error) {
+	_, entry, err := findEntry(service, method)
+	if err != nil {
+		return nil, err
+	}
+	return buildPayload(loadSchema(entry.Input), base, assignments)
+}
+
+// toJSON converts a JSON or YAML document to JSON. Documents that are valid
+// JSON are returned unchanged, so the payload hash matches what was given.
+func toJSON(data []byte) ([]byte, error) {
+	var value interface{}
+	if json.Unmarshal(data, &value) == nil {
+		return data, nil
+	}
+	value, err := ParseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// readPayload returns the payload for entry from the <payload> argument and
+// --set options. The <payload> argument may be a JSON or YAML string, '-' for
+// stdin, or '@file' to read from a JSON or YAML file. YAML is converted to
+// JSON, and payloads built with --set are validated against the input schema
+// before being returned.
+func readPayload(entry *definitions.Entry, argv map[string]interface{}) (io.Reader, error) {
+	assignments, _ := argv["--set"].([]string)
+	payload, hasPayload := argv["<payload>"].(string)
+
+	if !hasPayload && len(assignments) == 0 {
+		if entry.Input == "" {
+			return nil, nil
+		}
+		return nil, errors.New("a <payload> or --set options are required")
+	}
+
+	// Read the given payload as JSON
+	var data []byte
+	if hasPayload {
+		var err error
+		if payload == "-" {
+			if data, err = ioutil.ReadAll(os.Stdin); err == nil {
+				data, err = toJSON(data)
+			}
+		} else if strings.HasPrefix(payload, "@") {
+			var value interface{}
+			if value, err = loadFile(payload[1:]); err == nil {
+				data, err = json.Marshal(value)
+			}
+		} else {
+			data, err = toJSON([]byte(payload))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read payload, error: %s", err)
+		}
+	}
+
+	// Without assignments the payload is passed through as is
+	if len(assignments) == 0 {
+		return bytes.NewReader(data), nil
+	}
+
+	// Apply assignments on top of the base payload, if any
+	var base interface{}
+	if data != nil {
+		if err := json.Unmarshal(data, &base); err != nil {
+			return nil, fmt.Errorf("failed to read base payload, error: %s", err)
+		}
+	}
+
+	doc, err := buildPayload(loadSchema(entry.Input), base, assignments)
+	if err != nil {
+		return nil, err
+	}
+	data, _ = json.Marshal(doc)
+
+	// Validate the payload before it's sent
+	if _, ok := schemas[entry.Input]; ok {
+		problems, err := validate(entry.Input, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to validate payload, error: %s", err)
+		}
+		if len(problems) > 0 {
+			return nil, fmt.Errorf("payload is invalid:\n - %s", strings.Join(problems, "\n - "))
+		}
+	}
+	return bytes.NewReader(data), nil
+}
+
+// payloadFields returns a description of the top-level fields in schema,
+// one per line, with required fields marked.
+func payloadFields(schema map[string]interface{}) []string {
+	properties, _ := schema["properties"].(map[string]interface{})
+	required := make(map[string]bool)
+	if list, ok := schema["required"].([]interface{}); ok {
+		for _, r := range list {
+			if s, ok := r.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+
+	names := []string{}
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := []string{}
+	for _, name := range names {
+		prop, _ := properties[name].(map[string]interface{})
+		types := schemaTypes(prop)
+		if ref, ok := prop["$ref"].(string); ok && len(types) == 0 {
+			types = schemaTypes(loadSchema(ref))
+		}
+		field := name
+		if len(types) > 0 {
+			field += " (" + strings.Join(types, "|") + ")"
+		}
+		if required[name] {
+			field += " required"
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
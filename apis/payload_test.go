@@ -0,0 +1,113 @@
+package apis
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestParsePath(t *testing.T) {
+	assert := assert.New(t)
+
+	path, err := parsePath("payload.command[1].name")
+	assert.NoError(err)
+	assert.Equal([]pathSegment{
+		{key: "payload", isKey: true},
+		{key: "command", isKey: true},
+		{index: 1},
+		{key: "name", isKey: true},
+	}, path)
+
+	path, err = parsePath("scopes[]")
+	assert.NoError(err)
+	assert.Equal([]pathSegment{{key: "scopes", isKey: true}, {index: -1}}, path)
+
+	for _, invalid := range []string{"", "a..b", ".a", "a[x]", "a[0"} {
+		_, err = parsePath(invalid)
+		assert.Error(err, "path '%s' should be invalid", invalid)
+	}
+}
+
+func TestBuildPayload(t *testing.T) {
+	assert := assert.New(t)
+
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"retries": map[string]interface{}{"type": "integer"},
+			"name":    map[string]interface{}{"type": "string"},
+			"scopes": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string"},
+			},
+		},
+	}
+	base := map[string]interface{}{"name": "base"}
+
+	doc, err := buildPayload(schema, base, []string{
+		"retries=5",
+		"name=123",
+		"scopes[]=queue:a",
+		"scopes[]=queue:b",
+		"extra.enabled=true",
+	})
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{
+		"retries": int64(5),
+		"name":    "123",
+		"scopes":  []interface{}{"queue:a", "queue:b"},
+		"extra":   map[string]interface{}{"enabled": true},
+	}, doc)
+
+	_, err = buildPayload(schema, nil, []string{"retries=many"})
+	assert.Error(err)
+
+	_, err = buildPayload(schema, nil, []string{"scopes[3]=x"})
+	assert.Error(err)
+
+	_, err = buildPayload(schema, nil, []string{"retries"})
+	assert.Error(err)
+}
+
+func TestBuildInput(t *testing.T) {
+	assert := assert.New(t)
+
+	base := map[string]interface{}{"metadata": map[string]interface{}{"name": "base"}}
+	doc, err := BuildInput("queue", "createTask", base, []string{"retries=2", "metadata.name=123"})
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{
+		"retries":  int64(2),
+		"metadata": map[string]interface{}{"name": "123"},
+	}, doc)
+
+	_, err = BuildInput("queue", "noSuchMethod", nil, nil)
+	assert.Error(err)
+}
+
+func TestConvertYAML(t *testing.T) {
+	assert := assert.New(t)
+
+	value, err := ParseYAML([]byte("a:\n  b: [1, {c: d}]\n"))
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{
+		"a": map[string]interface{}{
+			"b": []interface{}{1, map[string]interface{}{"c": "d"}},
+		},
+	}, value)
+}
+
+func TestToJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	// JSON is passed through unchanged, so the payload hash is unaffected
+	data, err := toJSON([]byte(`{"b": 1,  "a": 2}`))
+	assert.NoError(err)
+	assert.Equal(`{"b": 1,  "a": 2}`, string(data))
+
+	data, err = toJSON([]byte("secret:\n  a: [1, 2]\n"))
+	assert.NoError(err)
+	assert.Equal(`{"secret":{"a":[1,2]}}`, string(data))
+
+	_, err = toJSON([]byte("a: [1"))
+	assert.Error(err)
+}
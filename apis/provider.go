@@ -2,6 +2,7 @@ package apis
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"hash"
 	"io"
@@ -10,11 +11,14 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"unicode"
 
 	"github.com/taskcluster/taskcluster-cli/apis/definitions"
 	"github.com/taskcluster/taskcluster-cli/client"
 	"github.com/taskcluster/taskcluster-cli/extpoints"
-	"github.com/xeipuuv/gojsonschema"
+	"github.com/taskcluster/taskcluster-cli/format"
+	"github.com/taskcluster/taskcluster-cli/query"
+	"github.com/taskcluster/taskcluster-cli/retry"
 )
 
 type apiProvider struct {
@@ -23,21 +27,69 @@ type apiProvider struct {
 }
 
 func (p apiProvider) ConfigOptions() map[string]extpoints.ConfigOption {
-	return map[string]extpoints.ConfigOption{
+	options := map[string]extpoints.ConfigOption{
 		"baseUrl": extpoints.ConfigOption{
-			Default: p.BaseURL,
-			Env:     "TASKCLUSTER_QUEUE_BASE_URL",
+			Description: "BaseUrl for " + p.Name + ", derived from config.rootUrl if empty.",
+			Default:     "",
+			Env:         p.baseURLEnv(),
 			Validate: func(value interface{}) error {
+				if _, ok := value.(string); !ok {
+					return errors.New("Must be a string")
+				}
 				return nil
 			},
 		},
+		"validateOutput": extpoints.ConfigOption{
+			Description: "Validate responses against the output schema by default.",
+			Default:     false,
+			Parse:       true,
+			Validate: func(value interface{}) error {
+				if _, ok := value.(bool); !ok {
+					return errors.New("Must be a boolean")
+				}
+				return nil
+			},
+		},
+	}
+	for key, option := range retry.ConfigOptions() {
+		options[key] = option
+	}
+	return options
+}
+
+// baseURLEnv returns the environment variable overriding the base URL of the
+// service, e.g. TASKCLUSTER_PURGE_CACHE_BASE_URL for purgeCache.
+func (p apiProvider) baseURLEnv() string {
+	name := ""
+	for i, c := range p.Name {
+		if unicode.IsUpper(c) && i > 0 {
+			name += "_"
+		}
+		name += string(unicode.ToUpper(c))
+	}
+	return "TASKCLUSTER_" + name + "_BASE_URL"
+}
+
+// serviceURL returns the base URL of the service in the deployment at
+// rootURL, using the service name and API version from the generated BaseURL.
+func (p apiProvider) serviceURL(rootURL string) string {
+	u, err := url.Parse(p.BaseURL)
+	if err != nil || u.Host == "" {
+		return p.BaseURL
 	}
+	service := strings.SplitN(u.Host, ".", 2)[0]
+	version := strings.Trim(u.Path, "/")
+	return client.ServiceURL(rootURL, service, version)
 }
 
 func (p apiProvider) Summary() string {
 	return "Operate on the " + p.Name + " service"
 }
 
+func (apiProvider) UsesCredentials() bool {
+	return true
+}
+
 func pad(s string, length int) string {
 	p := length - len(s)
 	if p < 0 {
@@ -46,6 +98,23 @@ func pad(s string, length int) string {
 	return s + strings.Repeat(" ", p)
 }
 
+// reservedOptions are the options of every service command, query-string
+// parameters with the same name are given as '--param-<name>' instead.
+var reservedOptions = map[string]bool{
+	"output": true, "base-url": true, "dry-run": true, "format": true,
+	"query": true, "validate-output": true, "max-retries": true,
+	"retry-timeout": true, "set": true, "all": true, "ndjson": true,
+	"skip-scope-check": true,
+}
+
+// queryOption returns the name of the option for query-string parameter q.
+func queryOption(q string) string {
+	if reservedOptions[q] {
+		return "param-" + q
+	}
+	return q
+}
+
 func (p apiProvider) Usage() string {
 	query := []string{}
 	usage := p.Title + "\n\n"
@@ -60,7 +129,7 @@ func (p apiProvider) Usage() string {
 			usage += " [--]" + args
 		}
 		for _, q := range e.Query {
-			usage += fmt.Sprintf(" [--%s <%s>]", q, q)
+			usage += fmt.Sprintf(" [--%s <%s>]", queryOption(q), q)
 			// Add q to opts, if not already in the list
 			unique := true
 			for _, o := range query {
@@ -73,21 +142,48 @@ func (p apiProvider) Usage() string {
 			}
 		}
 		if e.Input != "" {
-			usage += " <payload>"
+			usage += " [--set <assignment>]... [<payload>]"
 		}
 		usage += "\n"
 	}
+	for _, c := range localCommands[p.Name] {
+		usage += fmt.Sprintf("  taskcluster %s [options] %s %s\n", p.Name, c.Name, c.Pattern)
+	}
 	usage += fmt.Sprintf("  taskcluster %s help <method>", p.Name)
 	usage += "\n\n"
 	usage += "Options:\n"
 	opts := [][]string{
 		[]string{"-o, --output <output>", "Output file [default: -]"},
-		[]string{"-b, --base-url <baseUrl>", fmt.Sprintf("BaseUrl for %s [default: %s]", p.Name, p.BaseURL)},
+		[]string{"-b, --base-url <baseUrl>", "BaseUrl for " + p.Name + ", overrides the configured base URL"},
 		[]string{"-d, --dry-run", "Validate input again schema without making a request"},
+		[]string{"-f, --format <format>", "Output format (json | yaml | table)"},
+		[]string{"-q, --query <expr>", "Select values from the response, e.g. status.runs[-1].state"},
+		[]string{"    --validate-output", "Validate response against output schema"},
+		[]string{"    --max-retries <retries>", "Retry failed requests up to <retries> times"},
+		[]string{"    --retry-timeout <duration>", "Stop retrying failed requests after <duration>, e.g. '90 seconds'"},
+		[]string{"    --skip-scope-check", "Make requests even if credentials lack the required scopes"},
+	}
+	for _, e := range p.Entries {
+		if e.Input != "" {
+			opts = append(opts, []string{
+				"    --set <assignment>",
+				"Set a payload field, e.g. --set metadata.name=test",
+			})
+			break
+		}
+	}
+	for _, c := range localCommands[p.Name] {
+		opts = append(opts, c.Options...)
+	}
+	if p.paginated() {
+		opts = append(opts,
+			[]string{"-a, --all", "Follow continuationToken and merge all pages"},
+			[]string{"    --ndjson", "With --all, stream items as newline-delimited JSON"},
+		)
 	}
 	for _, opt := range query {
 		opts = append(opts, []string{
-			"    --" + opt + " <" + opt + ">",
+			"    --" + queryOption(opt) + " <" + opt + ">",
 			"Specify the '" + opt + "' query-string parameter",
 		})
 	}
@@ -111,6 +207,13 @@ func (p apiProvider) Usage() string {
 func (p apiProvider) Execute(context extpoints.Context) bool {
 	argv := context.Arguments
 
+	// Run local subcommands
+	for _, c := range localCommands[p.Name] {
+		if argv[c.Name] == true {
+			return c.Execute(context)
+		}
+	}
+
 	// Find then entry if possible
 	var entry *definitions.Entry
 	for _, e := range p.Entries {
@@ -130,25 +233,38 @@ func (p apiProvider) Execute(context extpoints.Context) bool {
 		for _, e := range p.Entries {
 			if e.Name == method {
 				entry = &e
+				break
+			}
+		}
+		for _, c := range localCommands[p.Name] {
+			if c.Name == method {
+				fmt.Printf("%s\n\n%s\n", c.Title, c.Description)
+				return true
 			}
 		}
 		if entry == nil {
 			fmt.Fprintf(os.Stderr, "Unknown method: '%s'\n", method)
+			return false
 		}
 		p.help(entry)
 		return true
 	}
 
-	// Read payload
-	var input io.Reader
-	if payload, ok := argv["<payload>"].(string); ok {
-		if payload == "-" {
-			input = os.Stdin
-		} else {
-			input = bytes.NewBufferString(payload)
+	// Check the query expression before making any requests
+	if expr, ok := argv["--query"].(string); ok {
+		if _, err := query.Parse(expr); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid query, error: %s\n", err)
+			return false
 		}
 	}
 
+	// Read payload
+	input, err := readPayload(entry, argv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to construct payload, error: %s\n", err)
+		return false
+	}
+
 	// Setup output
 	var output io.Writer = os.Stdout
 	if out := argv["--output"].(string); out != "-" {
@@ -170,7 +286,9 @@ func (p apiProvider) Execute(context extpoints.Context) bool {
 	// Construct query
 	query := make(map[string]string)
 	for _, opt := range entry.Query {
-		query[opt] = argv["--"+opt].(string)
+		if value, ok := argv["--"+queryOption(opt)].(string); ok {
+			query[opt] = value
+		}
 	}
 
 	// Do a dry run
@@ -178,12 +296,42 @@ func (p apiProvider) Execute(context extpoints.Context) bool {
 		return p.dryrun(entry, args, query, input, output)
 	}
 
+	// Check the credentials have the required scopes, before making requests
+	if argv["--skip-scope-check"] != true {
+		var data []byte
+		if input != nil {
+			data, _ = ioutil.ReadAll(input)
+			input = bytes.NewReader(data)
+		}
+		if explanation := missingScopes(entry, context.Credentials, scopeParams(args, query, data)); explanation != "" {
+			fmt.Fprint(os.Stderr, explanation)
+			fmt.Fprintln(os.Stderr, "Use --skip-scope-check to make the request anyway.")
+			return false
+		}
+	}
+
 	// Find baseURL
-	baseURL := context.Config["baseUrl"].(string)
+	baseURL, _ := context.Config["baseUrl"].(string)
+	if baseURL == "" {
+		baseURL = p.serviceURL(context.RootURL)
+	}
 	if s, ok := argv["--base-url"].(string); ok {
 		baseURL = s
 	}
 
+	// Fetch all pages, if requested
+	if argv["--all"] == true {
+		if !paginated(entry) {
+			fmt.Fprintf(os.Stderr, "Method '%s' does not support pagination\n", entry.Name)
+			return false
+		}
+		if argv["--ndjson"] == true && argv["--format"] != nil {
+			fmt.Fprintf(os.Stderr, "The --format option cannot be used with --ndjson\n")
+			return false
+		}
+		return p.executeAll(baseURL, entry, context, args, query, input, output, argv["--ndjson"] == true)
+	}
+
 	// Execute method
 	return p.execute(baseURL, entry, context, args, query, input, output)
 }
@@ -197,11 +345,17 @@ func (p apiProvider) help(entry *definitions.Entry) {
 	fmt.Printf("Scopes:\n")
 	for i, scopes := range entry.Scopes {
 		fmt.Printf("  %s", strings.Join(scopes, ","))
-		if i == len(scopes) {
+		if i < len(entry.Scopes)-1 {
 			fmt.Printf(", or")
 		}
 		fmt.Println("")
 	}
+	if fields := payloadFields(loadSchema(entry.Input)); len(fields) > 0 {
+		fmt.Printf("Payload:\n")
+		for _, field := range fields {
+			fmt.Printf("  %s\n", field)
+		}
+	}
 	fmt.Println(entry.Description)
 }
 
@@ -210,8 +364,7 @@ func (p apiProvider) dryrun(
 	payload io.Reader, output io.Writer,
 ) bool {
 	// If there is no schema, there is nothing to validate
-	schema, ok := schemas[entry.Input]
-	if !ok {
+	if _, ok := schemas[entry.Input]; !ok {
 		return true
 	}
 
@@ -220,23 +373,20 @@ func (p apiProvider) dryrun(
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to read input, error: %s\n", err)
 	}
-	input := gojsonschema.NewStringLoader(string(data))
 
 	// Validate against input schema
-	result, err := gojsonschema.Validate(
-		gojsonschema.NewStringLoader(schema), input,
-	)
+	problems, err := validate(entry.Input, data)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Validation failed, error: %s\n", err)
 		return false
 	}
 
 	// Print all validation errors
-	for _, e := range result.Errors() {
-		fmt.Fprintf(os.Stderr, " - %s\n", e.Description())
+	for _, e := range problems {
+		fmt.Fprintf(os.Stderr, " - %s\n", e)
 	}
 
-	return result.Valid()
+	return len(problems) == 0
 }
 
 func (p apiProvider) execute(
@@ -249,84 +399,136 @@ func (p apiProvider) execute(
 		data, err := ioutil.ReadAll(payload)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to read input, error: %s\n", err)
+			return false
 		}
 		input = data
 	}
 
-	// Parameterize the route
-	route := entry.Route
-	for k, v := range args {
-		val := strings.Replace(url.QueryEscape(v), "+", "%20", -1)
-		route = strings.Replace(route, "<"+k+">", val, 1)
+	res, err := p.request(baseURL, entry, context, args, query, input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Request failed, error: %s\n", err)
+		return false
+	}
+
+	// Read the response
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read response, error: %s\n", err)
+		return false
+	}
+
+	// Print the error response as is, if the request wasn't successful
+	if res.StatusCode/100 != 2 {
+		output.Write(data)
+		return false
+	}
+
+	// Validate the response against the output schema
+	valid := !validateOutputEnabled(context) || validateOutput(entry, data)
+
+	// Print the response to whatever output
+	if err = writeResult(context, data, output); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing result, error: %s\n", err)
+		return false
+	}
+
+	if !valid {
+		return context.Fail(exitSchemaDrift)
+	}
+	return true
+}
+
+// writeResult writes a successful response to output. If --query is given
+// only the selected values are written, and if --format is given the result
+// is rendered in that format, otherwise the response is written as is.
+func writeResult(context extpoints.Context, data []byte, output io.Writer) error {
+	expr, hasQuery := context.Arguments["--query"].(string)
+	f, hasFormat := context.Arguments["--format"].(string)
+	if !hasQuery && !hasFormat {
+		_, err := output.Write(data)
+		return err
+	}
+
+	value, err := format.Parse(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse response, error: %s", err)
 	}
 
-	// Create query options
-	qs := make(url.Values)
-	for k, v := range query {
-		qs.Add(k, v)
+	if hasQuery {
+		e, err := query.Parse(expr)
+		if err != nil {
+			return err
+		}
+		results, err := e.Evaluate(value)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate query, error: %s", err)
+		}
+		if !hasFormat {
+			_, err = output.Write(query.Render(results))
+			return err
+		}
+		if len(results) == 1 {
+			value = results[0]
+		} else {
+			value = results
+		}
 	}
-	q := qs.Encode()
-	if q != "" {
-		q = "?" + q
+
+	if data, err = format.Format(f, value); err != nil {
+		return err
 	}
+	_, err = output.Write(data)
+	return err
+}
 
+// request sends a single request for entry and returns the response, the
+// caller is responsible for closing the response body.
+func (p apiProvider) request(
+	baseURL string, entry *definitions.Entry, context extpoints.Context,
+	args, query map[string]string, input []byte,
+) (*http.Response, error) {
 	// Construct parameters
 	var method = strings.ToUpper(entry.Method)
-	var url = baseURL + route + q
+	var url = entryURL(baseURL, entry, args, query)
 	var body io.Reader
 	if len(input) > 0 {
 		body = bytes.NewReader(input)
 	}
 
-	// Try to make the request up to 5 times
-	var err error
-	var res *http.Response
-	for i := 0; i < 5; i++ {
-
-		// New request
-		req, err2 := http.NewRequest(method, url, body)
-		if err2 != nil {
-			panic(fmt.Sprintf("Internal error constructing request, error: %s", err))
-		}
+	// New request
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		panic(fmt.Sprintf("Internal error constructing request, error: %s", err))
+	}
 
-		// If there is a body, we set a content-type
-		if body != nil {
-			req.Header.Set("Content-Type", "application/json")
-		}
+	// If there is a body, we set a content-type
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
-		// Sign request if credentials are available
-		if context.Credentials != nil {
+	// Sign every attempt if credentials are available, so that each attempt
+	// gets a fresh nonce and timestamp
+	var sign func(req *http.Request, body []byte) error
+	if context.Credentials != nil {
+		sign = func(req *http.Request, body []byte) error {
 			var h hash.Hash
 			// Create payload hash if there is any
-			if body != nil {
+			if len(body) > 0 {
 				h = client.PayloadHash("application/json")
-				h.Write(input)
+				h.Write(body)
 			}
-			err2 := context.Credentials.SignRequest(req, h)
-			if err2 != nil {
-				fmt.Fprintf(os.Stderr, "Failed to sign request, error: %s\n", err2)
-				return false
+			if err := context.Credentials.SignRequest(req, h); err != nil {
+				return fmt.Errorf("Failed to sign request, error: %s", err)
 			}
+			return nil
 		}
-
-		// Send request
-		res, err = http.DefaultClient.Do(req)
-		// If error or 5xx we retry
-		if err != nil && res.StatusCode/100 == 5 {
-			continue
-		}
-		break
 	}
-	// Handle request errors
+
+	// Send request, retrying on connection errors and 5xx
+	opts, err := retry.FromContext(context)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Request failed\n")
-		return false
+		return nil, err
 	}
-
-	// Print the request to whatever output
-	defer res.Body.Close()
-	_, err = io.Copy(output, res.Body)
-
-	// Exit
-	return err == nil && res.StatusCode/100 == 2
+	return retry.NewClient(opts, sign).Do(req)
 }
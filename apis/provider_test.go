@@ -0,0 +1,36 @@
+package apis
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster-cli/apis/definitions"
+)
+
+func TestBaseURLEnv(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("TASKCLUSTER_AUTH_BASE_URL", apiProvider{Name: "auth"}.baseURLEnv())
+	assert.Equal("TASKCLUSTER_PURGE_CACHE_BASE_URL", apiProvider{Name: "purgeCache"}.baseURLEnv())
+	assert.Equal("TASKCLUSTER_AWS_PROVISIONER_BASE_URL", apiProvider{Name: "awsProvisioner"}.baseURLEnv())
+}
+
+func TestServiceURL(t *testing.T) {
+	assert := assert.New(t)
+
+	p := apiProvider{
+		Service: definitions.Service{BaseURL: "https://purge-cache.taskcluster.net/v1"},
+		Name:    "purgeCache",
+	}
+	assert.Equal("https://purge-cache.taskcluster.net/v1", p.serviceURL("https://taskcluster.net"))
+	assert.Equal("https://tc.example.com/api/purge-cache/v1", p.serviceURL("https://tc.example.com"))
+	assert.Equal("", apiProvider{Name: "authEvents"}.serviceURL("https://tc.example.com"))
+}
+
+func TestQueryOption(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("limit", queryOption("limit"))
+	assert.Equal("param-format", queryOption("format"))
+	assert.Equal("param-skip-scope-check", queryOption("skip-scope-check"))
+}
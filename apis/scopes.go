@@ -0,0 +1,92 @@
+package apis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/taskcluster/taskcluster-cli/apis/definitions"
+	"github.com/taskcluster/taskcluster-cli/client"
+	"github.com/taskcluster/taskcluster-cli/scopes"
+)
+
+// grantedScopes returns the scopes the credentials are limited to by their
+// authorizedScopes and certificate. It returns false if the scopes can't be
+// determined locally, as for permanent credentials without authorizedScopes,
+// or when scopes may be expanded by roles the auth service knows about.
+func grantedScopes(creds *client.Credentials) ([]string, bool) {
+	if creds == nil {
+		return nil, false
+	}
+	var have []string
+	known := false
+	if len(creds.AuthorizedScopes) > 0 {
+		have = creds.AuthorizedScopes
+		known = true
+	}
+	if cert, err := creds.ParseCertificate(); err == nil && cert != nil {
+		if known {
+			have = scopes.Intersect(have, cert.Scopes)
+		} else {
+			have = cert.Scopes
+			known = true
+		}
+	}
+	for _, scope := range have {
+		if strings.HasPrefix(scope, "assume:") || scopes.Satisfies([]string{scope}, "assume:") {
+			return nil, false
+		}
+	}
+	return have, known
+}
+
+// scopeParams returns the values for parameters in the scopes of an entry,
+// taken from route arguments, query-string and top-level payload fields.
+func scopeParams(args, query map[string]string, payload []byte) map[string]string {
+	params := make(map[string]string)
+	var doc map[string]interface{}
+	if json.Unmarshal(payload, &doc) == nil {
+		for key, value := range doc {
+			if s, ok := value.(string); ok {
+				params[key] = s
+			}
+		}
+	}
+	for key, value := range query {
+		params[key] = value
+	}
+	for key, value := range args {
+		params[key] = value
+	}
+	return params
+}
+
+// missingScopes returns an explanation of which scopes are missing, if the
+// credentials certainly don't satisfy the scopes required by entry, and ""
+// otherwise. Scope sets with parameters that can't be substituted are assumed
+// to be satisfied.
+func missingScopes(entry *definitions.Entry, creds *client.Credentials, params map[string]string) string {
+	have, known := grantedScopes(creds)
+	if !known || len(entry.Scopes) == 0 {
+		return ""
+	}
+
+	expression := scopes.SubstituteExpression(entry.Scopes, params)
+	explanation := fmt.Sprintf(
+		"The credentials lack the scopes required for '%s', which requires all scopes of one of these sets:\n",
+		entry.Name,
+	)
+	for _, set := range expression {
+		missing := scopes.Missing(have, set)
+		if len(missing) == 0 {
+			return ""
+		}
+		for _, scope := range missing {
+			if scopes.HasParameters(scope) {
+				return ""
+			}
+		}
+		explanation += fmt.Sprintf("  - %s\n    missing: %s\n", strings.Join(set, ", "), strings.Join(missing, ", "))
+	}
+	return explanation
+}
@@ -0,0 +1,73 @@
+package apis
+
+import (
+	"fmt"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster-cli/apis/definitions"
+	"github.com/taskcluster/taskcluster-cli/client"
+)
+
+func TestGrantedScopes(t *testing.T) {
+	assert := assert.New(t)
+
+	_, known := grantedScopes(nil)
+	assert.False(known)
+	_, known = grantedScopes(&client.Credentials{ClientID: "tester"})
+	assert.False(known)
+
+	have, known := grantedScopes(&client.Credentials{AuthorizedScopes: []string{"queue:*"}})
+	assert.True(known)
+	assert.Equal([]string{"queue:*"}, have)
+
+	have, known = grantedScopes(&client.Credentials{
+		AuthorizedScopes: []string{"queue:*", "auth:x"},
+		Certificate:      `{"scopes": ["queue:get-task:*", "index:*"]}`,
+	})
+	assert.True(known)
+	assert.Equal([]string{"queue:get-task:*"}, have)
+
+	// Roles can expand assume scopes to anything
+	_, known = grantedScopes(&client.Credentials{AuthorizedScopes: []string{"assume:project:x"}})
+	assert.False(known)
+	_, known = grantedScopes(&client.Credentials{AuthorizedScopes: []string{"*"}})
+	assert.False(known)
+}
+
+func TestScopeParams(t *testing.T) {
+	assert := assert.New(t)
+
+	params := scopeParams(
+		map[string]string{"taskId": "abc"},
+		map[string]string{"prefix": "p"},
+		[]byte(`{"provisionerId": "aws", "taskId": "other", "retries": 5}`),
+	)
+	assert.Equal(map[string]string{"taskId": "abc", "prefix": "p", "provisionerId": "aws"}, params)
+}
+
+func TestMissingScopes(t *testing.T) {
+	assert := assert.New(t)
+
+	entry := &definitions.Entry{
+		Name:   "createClient",
+		Scopes: [][]string{{"auth:create-client:<clientId>", "auth:x"}, {"auth:admin"}},
+	}
+	creds := func(scopes ...string) *client.Credentials {
+		return &client.Credentials{AuthorizedScopes: scopes}
+	}
+	params := map[string]string{"clientId": "tester"}
+
+	assert.Equal("", missingScopes(entry, creds("auth:create-client:*", "auth:x"), params))
+	assert.Equal("", missingScopes(entry, creds("auth:admin"), params))
+	assert.Equal("", missingScopes(entry, &client.Credentials{ClientID: "tester"}, params))
+	assert.Equal("", missingScopes(&definitions.Entry{}, creds("a"), params))
+
+	explanation := missingScopes(entry, creds("auth:create-client:*"), params)
+	assert.Contains(explanation, "'createClient'")
+	assert.Contains(explanation, fmt.Sprintf("  - auth:create-client:tester, auth:x\n    missing: auth:x\n"))
+	assert.Contains(explanation, "  - auth:admin\n    missing: auth:admin\n")
+
+	// Unknown parameters can't be checked
+	assert.Equal("", missingScopes(entry, creds("auth:y"), nil))
+}
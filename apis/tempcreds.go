@@ -0,0 +1,94 @@
+package apis
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/taskcluster/taskcluster-cli/extpoints"
+	"github.com/taskcluster/taskcluster-cli/format"
+	fromNow "github.com/taskcluster/taskcluster-cli/from-now"
+	"github.com/taskcluster/taskcluster-cli/scopes"
+)
+
+// clockDrift is subtracted from the start of temporary credentials, so they
+// can be used right away on hosts with clocks running a bit behind.
+const clockDrift = 5 * time.Minute
+
+var tempCredsCommand = localCommand{
+	Name:    "temp-creds",
+	Pattern: "[--scope <scope>]... [--expires <duration>] [--name <clientId>]",
+	Title:   "Issue Temporary Credentials",
+	Description: `Issue temporary credentials from the configured permanent credentials,
+without contacting the auth service. The temporary credentials can only have
+scopes satisfied by the authorizedScopes of the issuing credentials, if any
+are configured. They are printed as shell exports, or with --format json or
+--format yaml as a document with clientId, accessToken and certificate.`,
+	Options: [][]string{
+		{"    --scope <scope>", "Scope for temporary credentials, may be repeated"},
+		{"    --expires <duration>", "Time until temporary credentials expire [default: 1 hour]"},
+		{"    --name <clientId>", "ClientId of temporary credentials, to issue named credentials"},
+	},
+	Execute: tempCreds,
+}
+
+func tempCreds(context extpoints.Context) bool {
+	argv := context.Arguments
+	issuer := context.Credentials
+	if issuer == nil {
+		fmt.Fprintf(os.Stderr, "No credentials configured to issue temporary credentials from\n")
+		return false
+	}
+
+	// Refuse scopes the issuing credentials are not authorized for
+	scopeList, _ := argv["--scope"].([]string)
+	if len(issuer.AuthorizedScopes) > 0 {
+		for _, scope := range scopeList {
+			if !scopes.Satisfies(issuer.AuthorizedScopes, scope) {
+				fmt.Fprintf(os.Stderr, "Scope '%s' is not satisfied by the authorizedScopes of the issuing credentials\n", scope)
+				return false
+			}
+		}
+	}
+
+	expires := argv["--expires"].(string)
+	now := time.Now()
+	expiry, err := fromNow.Add(now, expires)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid duration '%s' given for --expires\n", expires)
+		return false
+	}
+	name, _ := argv["--name"].(string)
+
+	creds, err := issuer.CreateTemporaryCredentials(name, scopeList, now.Add(-clockDrift), expiry)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create temporary credentials, error: %s\n", err)
+		return false
+	}
+
+	// Print credentials
+	f, _ := argv["--format"].(string)
+	if f == "" || f == "env" {
+		fmt.Printf("export TASKCLUSTER_CLIENT_ID=%s\n", shellQuote(creds.ClientID))
+		fmt.Printf("export TASKCLUSTER_ACCESS_TOKEN=%s\n", shellQuote(creds.AccessToken))
+		fmt.Printf("export TASKCLUSTER_CERTIFICATE=%s\n", shellQuote(creds.Certificate))
+		return true
+	}
+	data, err := format.Format(f, map[string]interface{}{
+		"clientId":    creds.ClientID,
+		"accessToken": creds.AccessToken,
+		"certificate": creds.Certificate,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to format credentials, error: %s\n", err)
+		return false
+	}
+	os.Stdout.Write(data)
+	return true
+}
+
+// shellQuote quotes s for use as a single word in a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
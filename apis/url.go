@@ -0,0 +1,70 @@
+package apis
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/taskcluster/taskcluster-cli/apis/definitions"
+	"github.com/taskcluster/taskcluster-cli/config"
+)
+
+// EntryURL returns the URL of an API end-point, given by the name of the
+// service command and method, e.g. 'queue' and 'getLatestArtifact'. The base
+// URL is the one configured for the service, or derived from rootURL.
+func EntryURL(service, method, rootURL string, args, query map[string]string) (string, error) {
+	p, entry, err := findEntry(service, method)
+	if err != nil {
+		return "", err
+	}
+	for _, arg := range entry.Args {
+		if _, ok := args[arg]; !ok {
+			return "", fmt.Errorf("Missing argument '%s' for %s.%s", arg, service, method)
+		}
+	}
+	baseURL, _ := config.Configuration[service]["baseUrl"].(string)
+	if baseURL == "" {
+		baseURL = p.serviceURL(rootURL)
+	}
+	return entryURL(baseURL, entry, args, query), nil
+}
+
+// findEntry returns the provider and entry for an API end-point, given by the
+// name of the service command and method.
+func findEntry(service, method string) (apiProvider, *definitions.Entry, error) {
+	for name, s := range services {
+		if strings.ToLower(name[:1])+name[1:] != service {
+			continue
+		}
+		p := apiProvider{Name: service, Service: s}
+		for i := range s.Entries {
+			if s.Entries[i].Name == method {
+				return p, &s.Entries[i], nil
+			}
+		}
+		return p, nil, fmt.Errorf("Unknown method '%s' for service '%s'", method, service)
+	}
+	return apiProvider{}, nil, fmt.Errorf("Unknown service '%s'", service)
+}
+
+// entryURL returns the URL for entry with args substituted in the route and
+// query as query-string.
+func entryURL(baseURL string, entry *definitions.Entry, args, query map[string]string) string {
+	// Parameterize the route
+	route := entry.Route
+	for k, v := range args {
+		val := strings.Replace(url.QueryEscape(v), "+", "%20", -1)
+		route = strings.Replace(route, "<"+k+">", val, 1)
+	}
+
+	// Create query options
+	qs := make(url.Values)
+	for k, v := range query {
+		qs.Add(k, v)
+	}
+	q := qs.Encode()
+	if q != "" {
+		q = "?" + q
+	}
+	return baseURL + route + q
+}
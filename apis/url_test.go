@@ -0,0 +1,31 @@
+package apis
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestEntryURL(t *testing.T) {
+	assert := assert.New(t)
+
+	args := map[string]string{"taskId": "abc", "name": "private/build/target.tar.gz"}
+	u, err := EntryURL("queue", "getLatestArtifact", "https://taskcluster.net", args, nil)
+	assert.NoError(err)
+	assert.Equal("https://queue.taskcluster.net/v1/task/abc/artifacts/private%2Fbuild%2Ftarget.tar.gz", u)
+
+	u, err = EntryURL("queue", "getLatestArtifact", "https://tc.example.com", args, nil)
+	assert.NoError(err)
+	assert.Equal("https://tc.example.com/api/queue/v1/task/abc/artifacts/private%2Fbuild%2Ftarget.tar.gz", u)
+
+	u, err = EntryURL("auth", "listClients", "", nil, map[string]string{"prefix": "a b"})
+	assert.NoError(err)
+	assert.Equal("https://auth.taskcluster.net/v1/clients/?prefix=a+b", u)
+
+	_, err = EntryURL("queue", "getLatestArtifact", "", map[string]string{"taskId": "abc"}, nil)
+	assert.Error(err)
+	_, err = EntryURL("queue", "noSuchMethod", "", nil, nil)
+	assert.Error(err)
+	_, err = EntryURL("Queue", "task", "", nil, nil)
+	assert.Error(err)
+}
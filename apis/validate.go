@@ -0,0 +1,131 @@
+package apis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/taskcluster/taskcluster-cli/apis/definitions"
+	"github.com/taskcluster/taskcluster-cli/extpoints"
+	"github.com/xeipuuv/gojsonreference"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// exitSchemaDrift is the exit code used when a response doesn't match its
+// output schema, so scripts can tell it apart from a failed request.
+const exitSchemaDrift = 3
+
+// schemaLoader loads JSON schemas from the generated schemas map, so that
+// references between schemas are resolved without fetching them over HTTP.
+type schemaLoader struct {
+	source string
+}
+
+// schemaLoaderFactory creates schemaLoaders for referenced schemas.
+type schemaLoaderFactory struct{}
+
+func (schemaLoaderFactory) New(source string) gojsonschema.JSONLoader {
+	return schemaLoader{source: source}
+}
+
+func (l schemaLoader) JsonSource() interface{} {
+	return l.source
+}
+
+func (l schemaLoader) JsonReference() (gojsonreference.JsonReference, error) {
+	return gojsonreference.NewJsonReference(l.source)
+}
+
+func (l schemaLoader) LoaderFactory() gojsonschema.JSONLoaderFactory {
+	return schemaLoaderFactory{}
+}
+
+func (l schemaLoader) LoadJSON() (interface{}, error) {
+	ref, err := gojsonreference.NewJsonReference(l.source)
+	if err != nil {
+		return nil, err
+	}
+	u := *ref.GetUrl()
+	u.Fragment = ""
+
+	// Fall back to fetching the schema, if it isn't one we know about
+	schema, ok := schemas[u.String()+"#"]
+	if !ok {
+		return gojsonschema.NewReferenceLoader(l.source).LoadJSON()
+	}
+
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewBufferString(schema))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// validate validates data against the schema identified by schemaURL, and
+// returns a list of validation errors, which is empty if data is valid.
+func validate(schemaURL string, data []byte) ([]string, error) {
+	result, err := gojsonschema.Validate(
+		schemaLoader{source: schemaURL}, gojsonschema.NewBytesLoader(data),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []string{}
+	for _, e := range result.Errors() {
+		problems = append(problems, fmt.Sprintf("%s: %s", e.Field(), e.Description()))
+	}
+	return problems, nil
+}
+
+// ValidateInput validates data against the input schema of an API end-point,
+// given by the name of the service command and method, e.g. 'queue' and
+// 'createTask'. It returns a list of validation errors, which is empty if
+// data is valid or the end-point has no input schema.
+func ValidateInput(service, method string, data []byte) ([]string, error) {
+	_, entry, err := findEntry(service, method)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Input == "" {
+		return []string{}, nil
+	}
+	return validate(entry.Input, data)
+}
+
+// validateOutputEnabled returns true, if responses should be validated
+// against the output schema, either from --validate-output or configuration.
+func validateOutputEnabled(context extpoints.Context) bool {
+	if context.Arguments["--validate-output"] == true {
+		return true
+	}
+	enabled, _ := context.Config["validateOutput"].(bool)
+	return enabled
+}
+
+// validateOutput validates a response for entry against its output schema,
+// and reports any schema drift on stderr. Returns false, if data is invalid.
+func validateOutput(entry *definitions.Entry, data []byte) bool {
+	// If there is no schema, there is nothing to validate
+	if entry.Output == "" {
+		return true
+	}
+
+	problems, err := validate(entry.Output, data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Output validation failed, error: %s\n", err)
+		return false
+	}
+	if len(problems) == 0 {
+		return true
+	}
+
+	fmt.Fprintf(os.Stderr, "Schema drift: response from '%s' does not match %s\n", entry.Name, entry.Output)
+	for _, e := range problems {
+		fmt.Fprintf(os.Stderr, " - %s\n", e)
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package apis
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster-cli/apis/definitions"
+	"github.com/taskcluster/taskcluster-cli/extpoints"
+)
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+	schema := "http://schemas.taskcluster.net/queue/v1/list-artifacts-response.json#"
+
+	problems, err := validate(schema, []byte(`{"artifacts": []}`))
+	assert.NoError(err)
+	assert.Empty(problems)
+
+	problems, err = validate(schema, []byte(`{"artifacts": "nope"}`))
+	assert.NoError(err)
+	assert.Len(problems, 1)
+}
+
+func TestSchemaLoader(t *testing.T) {
+	assert := assert.New(t)
+	// Schemas from the generated schemas map must be loaded without fetching
+	// them, with or without the trailing fragment.
+	for _, source := range []string{
+		"http://schemas.taskcluster.net/queue/v1/task.json#",
+		"http://schemas.taskcluster.net/queue/v1/task.json",
+	} {
+		document, err := schemaLoader{source: source}.LoadJSON()
+		assert.NoError(err)
+		assert.Equal(
+			"http://schemas.taskcluster.net/queue/v1/task.json#",
+			document.(map[string]interface{})["id"],
+		)
+	}
+}
+
+func TestValidateInput(t *testing.T) {
+	assert := assert.New(t)
+
+	problems, err := ValidateInput("queue", "reportException", []byte(`{"reason": "malformed-payload"}`))
+	assert.NoError(err)
+	assert.Empty(problems)
+
+	problems, err = ValidateInput("queue", "reportException", []byte(`{"reason": "bored"}`))
+	assert.NoError(err)
+	assert.Len(problems, 1)
+
+	problems, err = ValidateInput("queue", "status", nil)
+	assert.NoError(err)
+	assert.Empty(problems)
+
+	_, err = ValidateInput("queue", "noSuchMethod", nil)
+	assert.Error(err)
+}
+
+func TestExecuteSchemaDrift(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"artifacts": "nope"}`))
+	}))
+	defer server.Close()
+
+	entry := &definitions.Entry{
+		Name:   "listArtifacts",
+		Method: "get",
+		Route:  "/artifacts",
+		Output: "http://schemas.taskcluster.net/queue/v1/list-artifacts-response.json#",
+	}
+	code := 1
+	context := extpoints.Context{
+		Arguments: map[string]interface{}{"--validate-output": true},
+		ExitCode:  &code,
+	}
+
+	// The response is written, but the command fails with exitSchemaDrift
+	var output bytes.Buffer
+	assert.False(apiProvider{}.execute(server.URL, entry, context, nil, nil, nil, &output))
+	assert.Equal(exitSchemaDrift, code)
+	assert.Equal(`{"artifacts": "nope"}`, output.String())
+}
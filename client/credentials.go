@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/tent/hawk-go"
 
@@ -35,7 +36,8 @@ func PayloadHash(contentType string) hash.Hash {
 	return a.PayloadHash(contentType)
 }
 
-type certificate struct {
+// Certificate is the certificate of temporary credentials.
+type Certificate struct {
 	Version   int      `json:"version"`
 	Scopes    []string `json:"scopes"`
 	Start     int64    `json:"start"`
@@ -46,10 +48,38 @@ type certificate struct {
 }
 
 type ext struct {
-	Certificate      *certificate `json:"certificate,omitempty"`
+	Certificate      *Certificate `json:"certificate,omitempty"`
 	AuthorizedScopes *[]string    `json:"authorizedScopes,omitempty"`
 }
 
+// IsTemporary returns true, if the credentials have a certificate.
+func (c *Credentials) IsTemporary() bool {
+	return c.Certificate != ""
+}
+
+// ParseCertificate returns the certificate of temporary credentials, or nil
+// for permanent credentials.
+func (c *Credentials) ParseCertificate() (*Certificate, error) {
+	if !c.IsTemporary() {
+		return nil, nil
+	}
+	var cert Certificate
+	if err := json.Unmarshal([]byte(c.Certificate), &cert); err != nil {
+		return nil, fmt.Errorf("Failed to parse certificate, error: %s", err)
+	}
+	return &cert, nil
+}
+
+// StartTime returns the time from which the certificate is valid.
+func (cert *Certificate) StartTime() time.Time {
+	return time.Unix(0, cert.Start*int64(time.Millisecond))
+}
+
+// ExpiryTime returns the time at which the certificate expires.
+func (cert *Certificate) ExpiryTime() time.Time {
+	return time.Unix(0, cert.Expiry*int64(time.Millisecond))
+}
+
 func nonce() string {
 	b := make([]byte, 8)
 	_, err := io.ReadFull(rand.Reader, b)
@@ -105,13 +135,18 @@ func (c *Credentials) SignHeader(method, url string, h hash.Hash) (string, error
 	return a.RequestHeader(), nil
 }
 
-// SignURL will generate a (bewit) signed URL
-func (c *Credentials) SignURL(URL string) (string, error) {
+// SignURL will generate a (bewit) signed URL, which is valid for expiration
+func (c *Credentials) SignURL(URL string, expiration time.Duration) (string, error) {
 	a, err := c.newAuth("GET", URL, nil)
 	if err != nil {
 		return "", err
 	}
-	URL += "?bewit=" + url.QueryEscape(a.Bewit())
+	a.Timestamp = time.Now().Add(expiration)
+	if strings.Contains(URL, "?") {
+		URL += "&bewit=" + url.QueryEscape(a.Bewit())
+	} else {
+		URL += "?bewit=" + url.QueryEscape(a.Bewit())
+	}
 	return URL, nil
 }
 
@@ -2,12 +2,15 @@ package client
 
 import (
 	"crypto/sha1"
+	"crypto/sha256"
 	"hash"
 	http "net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	tcclient "github.com/taskcluster/taskcluster-client-go"
+	"github.com/tent/hawk-go"
 
 	assert "github.com/stretchr/testify/require"
 )
@@ -75,3 +78,35 @@ func TestCredentialsToTCC(t *testing.T) {
 	assert.IsType(&tcclient.Credentials{}, creds, "credentials should be of correct type")
 	assert.Equal(testTCCCredentials, creds, "credentials should match")
 }
+
+func TestSignURL(t *testing.T) {
+	assert := assert.New(t)
+
+	validate := func(signed string) error {
+		request := http.NewRequest("GET", signed, nil)
+		auth, err := hawk.NewAuthFromRequest(request, func(c *hawk.Credentials) error {
+			c.Key = credentials.AccessToken
+			c.Hash = sha256.New
+			return nil
+		}, nil)
+		if err != nil {
+			return err
+		}
+		return auth.Valid()
+	}
+
+	for _, u := range []string{
+		"https://queue.taskcluster.net/v1/task/abc/artifacts/private/log.txt",
+		"https://index.taskcluster.net/v1/tasks/ns?limit=5",
+	} {
+		signed, err := credentials.SignURL(u, time.Hour)
+		assert.NoError(err)
+		assert.Contains(signed, "bewit=")
+		assert.Equal(1, strings.Count(signed, "?"))
+		assert.NoError(validate(signed))
+	}
+
+	signed, err := credentials.SignURL("https://queue.taskcluster.net/v1/task/abc", -time.Minute)
+	assert.NoError(err)
+	assert.Equal(hawk.ErrBewitExpired, validate(signed))
+}
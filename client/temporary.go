@@ -0,0 +1,82 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/taskcluster/slugid-go/slugid"
+)
+
+// MaxTemporaryCredentialsLifetime is the longest time temporary credentials
+// can be valid for.
+const MaxTemporaryCredentialsLifetime = 31 * 24 * time.Hour
+
+// CreateTemporaryCredentials returns temporary credentials for scopes, valid
+// from start until expiry, issued by the permanent credentials c. If name is
+// not empty, the temporary credentials have name as clientId and c is
+// recorded as issuer, otherwise they have the clientId of c.
+func (c *Credentials) CreateTemporaryCredentials(name string, scopes []string, start, expiry time.Time) (*Credentials, error) {
+	if c.IsTemporary() {
+		return nil, errors.New("temporary credentials cannot be used to issue temporary credentials")
+	}
+	if !expiry.After(start) {
+		return nil, errors.New("expiry must be after start")
+	}
+	if expiry.Sub(start) > MaxTemporaryCredentialsLifetime {
+		return nil, errors.New("temporary credentials cannot be valid for more than 31 days")
+	}
+	if name == c.ClientID {
+		return nil, errors.New("the name of temporary credentials must differ from the clientId of the issuer")
+	}
+
+	cert := &Certificate{
+		Version: 1,
+		Scopes:  scopes,
+		Start:   start.UnixNano() / int64(time.Millisecond),
+		Expiry:  expiry.UnixNano() / int64(time.Millisecond),
+		Seed:    slugid.V4() + slugid.V4(),
+	}
+	if cert.Scopes == nil {
+		cert.Scopes = []string{}
+	}
+
+	// Sign the certificate
+	lines := []string{"version:1"}
+	clientID := c.ClientID
+	if name != "" {
+		clientID = name
+		cert.Issuer = c.ClientID
+		lines = append(lines, "clientId:"+name, "issuer:"+c.ClientID)
+	}
+	lines = append(lines,
+		"seed:"+cert.Seed,
+		"start:"+strconv.FormatInt(cert.Start, 10),
+		"expiry:"+strconv.FormatInt(cert.Expiry, 10),
+		"scopes:",
+	)
+	lines = append(lines, cert.Scopes...)
+	mac := hmac.New(sha256.New, []byte(c.AccessToken))
+	mac.Write([]byte(strings.Join(lines, "\n")))
+	cert.Signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	// Derive the access token from the seed
+	mac = hmac.New(sha256.New, []byte(c.AccessToken))
+	mac.Write([]byte(cert.Seed))
+	accessToken := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	data, err := json.Marshal(cert)
+	if err != nil {
+		return nil, err
+	}
+	return &Credentials{
+		ClientID:    clientID,
+		AccessToken: accessToken,
+		Certificate: string(data),
+	}, nil
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestCreateTemporaryCredentials(t *testing.T) {
+	assert := assert.New(t)
+
+	start := time.Unix(1500000000, 0)
+	expiry := start.Add(time.Hour)
+	temp, err := credentials.CreateTemporaryCredentials("", []string{"scope:a", "scope:b"}, start, expiry)
+	assert.NoError(err)
+	assert.Equal("tester", temp.ClientID)
+
+	cert, err := temp.ParseCertificate()
+	assert.NoError(err)
+	assert.Equal(1, cert.Version)
+	assert.Equal([]string{"scope:a", "scope:b"}, cert.Scopes)
+	assert.Equal(start, cert.StartTime())
+	assert.Equal(expiry, cert.ExpiryTime())
+	assert.Equal("", cert.Issuer)
+	assert.Len(cert.Seed, 44)
+
+	mac := hmac.New(sha256.New, []byte("no-secret"))
+	mac.Write([]byte("version:1\nseed:" + cert.Seed + "\nstart:1500000000000\nexpiry:1500003600000\nscopes:\nscope:a\nscope:b"))
+	assert.Equal(base64.StdEncoding.EncodeToString(mac.Sum(nil)), cert.Signature)
+
+	mac = hmac.New(sha256.New, []byte("no-secret"))
+	mac.Write([]byte(cert.Seed))
+	assert.Equal(base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), temp.AccessToken)
+}
+
+func TestCreateNamedTemporaryCredentials(t *testing.T) {
+	assert := assert.New(t)
+
+	start := time.Unix(1500000000, 0)
+	temp, err := credentials.CreateTemporaryCredentials("ci-job", nil, start, start.Add(time.Hour))
+	assert.NoError(err)
+	assert.Equal("ci-job", temp.ClientID)
+
+	cert, err := temp.ParseCertificate()
+	assert.NoError(err)
+	assert.Equal("tester", cert.Issuer)
+	assert.Equal([]string{}, cert.Scopes)
+
+	mac := hmac.New(sha256.New, []byte("no-secret"))
+	mac.Write([]byte("version:1\nclientId:ci-job\nissuer:tester\nseed:" + cert.Seed + "\nstart:1500000000000\nexpiry:1500003600000\nscopes:"))
+	assert.Equal(base64.StdEncoding.EncodeToString(mac.Sum(nil)), cert.Signature)
+}
+
+func TestCreateTemporaryCredentialsInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	start := time.Unix(1500000000, 0)
+	_, err := credentials.CreateTemporaryCredentials("", nil, start, start.Add(32*24*time.Hour))
+	assert.Error(err)
+	_, err = credentials.CreateTemporaryCredentials("", nil, start, start)
+	assert.Error(err)
+	_, err = credentials.CreateTemporaryCredentials("tester", nil, start, start.Add(time.Hour))
+	assert.Error(err)
+
+	temp, err := credentials.CreateTemporaryCredentials("", nil, start, start.Add(time.Hour))
+	assert.NoError(err)
+	_, err = temp.CreateTemporaryCredentials("", nil, start, start.Add(time.Hour))
+	assert.Error(err)
+}
@@ -0,0 +1,31 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultRootURL is the root URL of the production taskcluster deployment.
+const DefaultRootURL = "https://taskcluster.net"
+
+// ServiceURL returns the base URL for version of service in the deployment
+// at rootURL. The production deployment uses a host per service, e.g.
+// https://queue.taskcluster.net/v1, while other deployments serve every
+// service under the root, e.g. https://tc.example.com/api/queue/v1.
+func ServiceURL(rootURL, service, version string) string {
+	rootURL = strings.TrimRight(rootURL, "/")
+	if rootURL == "" || rootURL == DefaultRootURL {
+		return fmt.Sprintf("https://%s.taskcluster.net/%s", service, version)
+	}
+	return fmt.Sprintf("%s/api/%s/%s", rootURL, service, version)
+}
+
+// InspectorURL returns the URL of the page showing the task with taskID in the
+// web UI of the deployment at rootURL.
+func InspectorURL(rootURL, taskID string) string {
+	rootURL = strings.TrimRight(rootURL, "/")
+	if rootURL == "" || rootURL == DefaultRootURL {
+		return "https://tools.taskcluster.net/task-inspector/#" + taskID
+	}
+	return fmt.Sprintf("%s/tasks/%s", rootURL, taskID)
+}
@@ -0,0 +1,25 @@
+package client
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestServiceURL(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("https://queue.taskcluster.net/v1", ServiceURL(DefaultRootURL, "queue", "v1"))
+	assert.Equal("https://queue.taskcluster.net/v1", ServiceURL("https://taskcluster.net/", "queue", "v1"))
+	assert.Equal("https://queue.taskcluster.net/v1", ServiceURL("", "queue", "v1"))
+	assert.Equal("https://tc.example.com/api/purge-cache/v1", ServiceURL("https://tc.example.com", "purge-cache", "v1"))
+	assert.Equal("https://tc.example.com/api/auth/v1", ServiceURL("https://tc.example.com/", "auth", "v1"))
+}
+
+func TestInspectorURL(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("https://tools.taskcluster.net/task-inspector/#abc", InspectorURL(DefaultRootURL, "abc"))
+	assert.Equal("https://tools.taskcluster.net/task-inspector/#abc", InspectorURL("", "abc"))
+	assert.Equal("https://tc.example.com/tasks/abc", InspectorURL("https://tc.example.com/", "abc"))
+}
@@ -6,13 +6,16 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"sort"
 	"strings"
 
+	"github.com/taskcluster/taskcluster-cli/client"
 	"github.com/taskcluster/taskcluster-cli/extpoints"
+	"github.com/taskcluster/taskcluster-cli/format"
+	fromNow "github.com/taskcluster/taskcluster-cli/from-now"
 	"github.com/taskcluster/taskcluster-client-go"
-	"gopkg.in/yaml.v2"
 )
 
 type cfg struct{}
@@ -28,6 +31,26 @@ var isString = func(value interface{}) error {
 	return nil
 }
 
+// stringList converts a list of strings, as loaded from JSON or YAML, to
+// []string.
+func stringList(value interface{}) ([]string, bool) {
+	switch v := value.(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		list := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			list[i] = s
+		}
+		return list, true
+	}
+	return nil, false
+}
+
 func pad(s string, length int) string {
 	p := length - len(s)
 	if p < 0 {
@@ -37,7 +60,7 @@ func pad(s string, length int) string {
 }
 
 func (cfg) ConfigOptions() map[string]extpoints.ConfigOption {
-	return map[string]extpoints.ConfigOption{
+	options := map[string]extpoints.ConfigOption{
 		"clientId": extpoints.ConfigOption{
 			Description: "ClientId to be used for authenticating requests",
 			Default:     "",
@@ -70,14 +93,45 @@ func (cfg) ConfigOptions() map[string]extpoints.ConfigOption {
 			Description: `Set of scopes to be used for authorizing requests, defaults to all the scopes you have.`,
 			Parse:       true,
 			Validate: func(value interface{}) error {
-				_, ok := value.([]string)
-				if !ok {
+				if _, ok := stringList(value); !ok {
 					return errors.New("Must be a list of strings")
 				}
 				return nil
 			},
 		},
+		"rootUrl": extpoints.ConfigOption{
+			Description: "Root URL of the taskcluster deployment, service base URLs are derived from it.",
+			Default:     client.DefaultRootURL,
+			Env:         "TASKCLUSTER_ROOT_URL",
+			Validate: func(value interface{}) error {
+				s, ok := value.(string)
+				if !ok {
+					return errors.New("Must be a string")
+				}
+				u, err := url.Parse(s)
+				if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					return errors.New("Must be an absolute http or https URL")
+				}
+				return nil
+			},
+		},
+		"certificateExpiryWarning": extpoints.ConfigOption{
+			Description: "Warn when temporary credentials expire within this duration, e.g. '30 minutes', '0 minutes' disables the warning.",
+			Default:     "15 minutes",
+			Validate: func(value interface{}) error {
+				s, ok := value.(string)
+				if !ok {
+					return errors.New("Must be a string")
+				}
+				_, err := fromNow.Duration(s)
+				return err
+			},
+		},
+	}
+	for key, option := range credentialStoreOptions {
+		options[key] = option
 	}
+	return options
 }
 
 func (cfg) Summary() string {
@@ -93,23 +147,38 @@ func (cfg) Usage() string {
 	usage += "  taskcluster config [options] set <key> <value> [--dry-run]\n"
 	usage += "  taskcluster config [options] reset [<key>]\n"
 	usage += "  taskcluster config help [<key>]\n"
+	usage += "  taskcluster config profile create <name> [--inherits <profile>]\n"
+	usage += "  taskcluster config profile use <name>\n"
+	usage += "  taskcluster config profile list\n"
+	usage += "  taskcluster config profile delete <name>\n"
 	usage += "\n"
 	usage += "Options:\n"
 	usage += "  -o, --output <file>         Write output to file [default: -]\n"
 	usage += "  -d, --dry-run               Validate option only, don't set it\n"
 	usage += "  -f, --format (json | yaml)  Select output format [default: yaml]\n"
+	usage += "  --inherits <profile>        Profile to inherit values from [default: default]\n"
 	usage += "\n"
 	usage += "The configuration options for the taskcluster command line interface\n"
 	usage += "is stored in:\n"
 	usage += "    " + configFile() + "\n"
 	usage += "The location can be modified with the environment variable\n"
 	usage += "XDG_CONFIG_HOME.\n"
+	usage += "\n"
+	usage += "Options are stored in named profiles, values not set in a profile are\n"
+	usage += "inherited from the profile given with --inherits when it was created.\n"
+	usage += "The active profile is selected with 'taskcluster config profile use',\n"
+	usage += "the environment variable TASKCLUSTER_PROFILE or the global option\n"
+	usage += "--profile, in increasing order of precedence.\n"
 	return usage
 }
 
 func (cfg) Execute(context extpoints.Context) bool {
 	argv := context.Arguments
 
+	if argv["profile"] == true {
+		return executeProfile(argv)
+	}
+
 	// Load configuration
 	config, err := Load()
 	if err != nil {
@@ -241,15 +310,14 @@ func (cfg) Execute(context extpoints.Context) bool {
 		}
 
 	} else {
-		// Select formatter
-		formatter := formatYAML
-		if f, ok := argv["--format"].(string); ok {
-			if f == "json" {
-				formatter = formatJSON
-			} else if f != "yaml" {
-				fmt.Fprintf(os.Stderr, "Unsupported output format: %s\n", f)
-				return false
-			}
+		// Select format
+		f := "yaml"
+		if value, ok := argv["--format"].(string); ok {
+			f = value
+		}
+		if f != "json" && f != "yaml" {
+			fmt.Fprintf(os.Stderr, "Unsupported output format: %s\n", f)
+			return false
 		}
 
 		// Open output file
@@ -272,7 +340,11 @@ func (cfg) Execute(context extpoints.Context) bool {
 		}
 
 		// Write output
-		if _, err := out.Write(formatter(value)); err != nil {
+		data, err := format.Format(f, value)
+		if err != nil {
+			panic(fmt.Sprintf("Internal error rendering %s, error: %s", f, err))
+		}
+		if _, err := out.Write(data); err != nil {
 			fmt.Fprintf(os.Stderr, "Error writing result, error: %s\n", err)
 			return false
 		}
@@ -280,22 +352,6 @@ func (cfg) Execute(context extpoints.Context) bool {
 	return true
 }
 
-func formatYAML(value interface{}) []byte {
-	data, err := yaml.Marshal(value)
-	if err != nil {
-		panic(fmt.Sprintf("Internal error rendering yaml, error: %s", err))
-	}
-	return data
-}
-
-func formatJSON(value interface{}) []byte {
-	data, err := json.MarshalIndent(value, "", "  ")
-	if err != nil {
-		panic(fmt.Sprintf("Internal error rendering json, error: %s", err))
-	}
-	return data
-}
-
 // printOptionHelp shows help for specific option
 func printOptionHelp(name, key string, option extpoints.ConfigOption, value interface{}) {
 	defaultValue := option.Default
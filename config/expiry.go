@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/taskcluster/taskcluster-cli/client"
+)
+
+// expiryWarning returns a warning if the certificate of credentials has
+// expired, or expires within window of now, and "" otherwise.
+func expiryWarning(credentials *client.Credentials, window time.Duration, now time.Time) string {
+	if credentials == nil {
+		return ""
+	}
+	cert, err := credentials.ParseCertificate()
+	if err != nil {
+		return fmt.Sprintf("Warning: %s", err)
+	}
+	if cert == nil {
+		return ""
+	}
+
+	expiry := cert.ExpiryTime()
+	if !expiry.After(now) {
+		return fmt.Sprintf(
+			"Warning: temporary credentials expired %s ago, run 'taskcluster signin' to renew them",
+			now.Sub(expiry)/time.Second*time.Second,
+		)
+	}
+	if window > 0 && expiry.Sub(now) <= window {
+		return fmt.Sprintf(
+			"Warning: temporary credentials expire in %s, run 'taskcluster signin' to renew them",
+			expiry.Sub(now)/time.Second*time.Second,
+		)
+	}
+	return ""
+}
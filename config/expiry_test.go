@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster-cli/client"
+)
+
+func TestExpiryWarning(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Unix(1500000000, 0)
+	temporary := func(expiry time.Time) *client.Credentials {
+		return &client.Credentials{
+			ClientID: "tester",
+			Certificate: fmt.Sprintf(`{"version":1,"start":%d,"expiry":%d}`,
+				now.Add(-time.Hour).Unix()*1000, expiry.Unix()*1000),
+		}
+	}
+
+	assert.Equal("", expiryWarning(nil, time.Hour, now))
+	assert.Equal("", expiryWarning(&client.Credentials{ClientID: "tester"}, time.Hour, now))
+	assert.Equal("", expiryWarning(temporary(now.Add(2*time.Hour)), time.Hour, now))
+	assert.Contains(expiryWarning(temporary(now.Add(30*time.Minute)), time.Hour, now), "expire in 30m0s")
+	assert.Equal("", expiryWarning(temporary(now.Add(30*time.Minute)), 0, now))
+	assert.Contains(expiryWarning(temporary(now.Add(-90*time.Second)), time.Hour, now), "expired 1m30s ago")
+	assert.Contains(expiryWarning(&client.Credentials{Certificate: "{"}, time.Hour, now), "Failed to parse certificate")
+
+	// The warning window is a time expression, like other durations
+	option := cfg{}.ConfigOptions()["certificateExpiryWarning"]
+	assert.NoError(option.Validate(option.Default))
+	assert.NoError(option.Validate("0 minutes"))
+	assert.Error(option.Validate("15m"))
+}
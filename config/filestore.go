@@ -0,0 +1,236 @@
+package config
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/taskcluster/taskcluster-cli/client"
+)
+
+// pbkdf2Iterations is the number of iterations used to derive keys from
+// passphrases for new credential files.
+const pbkdf2Iterations = 100000
+
+// credentialsFile is the location of the encrypted credentials file.
+func credentialsFile() string {
+	return filepath.Join(filepath.Dir(configFile()), "taskcluster-credentials")
+}
+
+// encryptedFile is the layout of the credentials file, data holds the
+// encrypted JSON map from profile name to credentials.
+type encryptedFile struct {
+	Salt       []byte `json:"salt"`
+	Iterations int    `json:"iterations"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// fileStore stores credentials in a file readable only by the user, encrypted
+// with a key derived from a passphrase.
+type fileStore struct {
+	path string
+	// passphrase returns the passphrase to use, it's called at most once.
+	passphrase func() (string, error)
+	// cached passphrase, empty until it has been read
+	cached string
+}
+
+func newFileStore(path string) *fileStore {
+	return &fileStore{path: path, passphrase: readPassphrase}
+}
+
+// readPassphrase returns the passphrase from TASKCLUSTER_CREDENTIALS_PASSPHRASE,
+// or prompts for it on the terminal.
+func readPassphrase() (string, error) {
+	if passphrase := os.Getenv("TASKCLUSTER_CREDENTIALS_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", errors.New("no terminal to prompt for passphrase, set TASKCLUSTER_CREDENTIALS_PASSPHRASE")
+	}
+	defer tty.Close()
+
+	// Disable echo while the passphrase is typed, if possible
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = tty
+		return cmd.Run()
+	}
+	if stty("-echo") == nil {
+		defer stty("echo")
+	}
+
+	fmt.Fprint(tty, "Passphrase for taskcluster credentials: ")
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	fmt.Fprintln(tty)
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read passphrase, error: %s", err)
+	}
+	passphrase := strings.TrimRight(line, "\r\n")
+	if passphrase == "" {
+		return "", errors.New("passphrase must not be empty")
+	}
+	return passphrase, nil
+}
+
+func (s *fileStore) key(salt []byte, iterations int) ([]byte, error) {
+	if s.cached == "" {
+		passphrase, err := s.passphrase()
+		if err != nil {
+			return nil, err
+		}
+		s.cached = passphrase
+	}
+	return pbkdf2([]byte(s.cached), salt, iterations, 32), nil
+}
+
+// read decrypts and returns all stored credentials by profile.
+func (s *fileStore) read() (map[string]*client.Credentials, error) {
+	credentials := make(map[string]*client.Credentials)
+	raw, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return credentials, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file, error: %s", err)
+	}
+
+	var f encryptedFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials file %s, error: %s", s.path, err)
+	}
+	key, err := s.key(f.Salt, f.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := gcm.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credentials file %s, wrong passphrase?", s.path)
+	}
+	if err := json.Unmarshal(data, &credentials); err != nil {
+		return nil, fmt.Errorf("failed to parse decrypted credentials, error: %s", err)
+	}
+	return credentials, nil
+}
+
+// write encrypts and saves credentials with a new salt and nonce.
+func (s *fileStore) write(credentials map[string]*client.Credentials) error {
+	f := encryptedFile{
+		Salt:       make([]byte, 16),
+		Iterations: pbkdf2Iterations,
+	}
+	if _, err := io.ReadFull(rand.Reader, f.Salt); err != nil {
+		return err
+	}
+	key, err := s.key(f.Salt, f.Iterations)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, f.Nonce); err != nil {
+		return err
+	}
+	data, err := json.Marshal(credentials)
+	if err != nil {
+		panic(fmt.Sprintf("internal error: could not marshal credentials: %s", err))
+	}
+	f.Data = gcm.Seal(nil, f.Nonce, data, nil)
+
+	raw, _ := json.MarshalIndent(f, "", "  ")
+	if err := ioutil.WriteFile(s.path, raw, 0600); err != nil {
+		return fmt.Errorf("failed to write credentials file, error: %s", err)
+	}
+	// WriteFile doesn't change the permissions of an existing file
+	return os.Chmod(s.path, 0600)
+}
+
+func (s *fileStore) Get(profile string) (*client.Credentials, error) {
+	credentials, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return credentials[profile], nil
+}
+
+func (s *fileStore) Store(profile string, c *client.Credentials) error {
+	credentials, err := s.read()
+	if err != nil {
+		return err
+	}
+	credentials[profile] = c
+	return s.write(credentials)
+}
+
+func (s *fileStore) Erase(profile string) error {
+	credentials, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := credentials[profile]; !ok {
+		return nil
+	}
+	delete(credentials, profile)
+	return s.write(credentials)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2 derives a key of keyLen bytes from password using PBKDF2 with
+// HMAC-SHA256, as specified in RFC 2898.
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	key := make([]byte, 0, blocks*hashLen)
+	u := make([]byte, hashLen)
+	var counter [4]byte
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(counter[:], uint32(block))
+		prf.Write(counter[:])
+		key = prf.Sum(key)
+		t := key[len(key)-hashLen:]
+		copy(u, t)
+
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range u {
+				t[j] ^= u[j]
+			}
+		}
+	}
+	return key[:keyLen]
+}
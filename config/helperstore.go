@@ -0,0 +1,76 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/taskcluster/taskcluster-cli/client"
+)
+
+// helperStore stores credentials using an external command, similar to git
+// credential helpers. The command is called with the action ('get', 'store'
+// or 'erase') as last argument and a JSON request on stdin:
+//
+//	{"profile": "default", "credentials": {"clientId": ..., ...}}
+//
+// where credentials is only given for 'store'. For 'get' the command must
+// write the credentials as JSON to stdout, or nothing if there are none.
+type helperStore struct {
+	command string
+}
+
+type helperRequest struct {
+	Profile     string              `json:"profile"`
+	Credentials *client.Credentials `json:"credentials,omitempty"`
+}
+
+// run calls the helper with action and request, returning its output.
+func (s helperStore) run(action string, request helperRequest) ([]byte, error) {
+	args := strings.Fields(s.command)
+	if len(args) == 0 {
+		return nil, errors.New("credential helper command is empty")
+	}
+	input, _ := json.Marshal(request)
+
+	cmd := exec.Command(args[0], append(args[1:], action)...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("credential helper '%s %s' failed, error: %s", s.command, action, err)
+	}
+	return output, nil
+}
+
+func (s helperStore) Get(profile string) (*client.Credentials, error) {
+	output, err := s.run("get", helperRequest{Profile: profile})
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(output)) == 0 {
+		return nil, nil
+	}
+	var credentials client.Credentials
+	if err := json.Unmarshal(output, &credentials); err != nil {
+		return nil, fmt.Errorf("failed to parse output from credential helper, error: %s", err)
+	}
+	if credentials.ClientID == "" {
+		return nil, nil
+	}
+	return &credentials, nil
+}
+
+func (s helperStore) Store(profile string, credentials *client.Credentials) error {
+	_, err := s.run("store", helperRequest{Profile: profile, Credentials: credentials})
+	return err
+}
+
+func (s helperStore) Erase(profile string) error {
+	_, err := s.run("erase", helperRequest{Profile: profile})
+	return err
+}
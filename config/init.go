@@ -3,41 +3,51 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/taskcluster/taskcluster-cli/client"
+	fromNow "github.com/taskcluster/taskcluster-cli/from-now"
 )
 
 var (
-	Configuration map[string]map[string]interface{}
+	Configuration      map[string]map[string]interface{}
 	OptionsDefinitions = make(map[string]map[string]OptionDefinition)
-	Credentials   *client.Credentials
+	Credentials        *client.Credentials
+	RootURL            string
 )
 
 // Setup is to be called from main
 // this was originally the init() function
 // but we want to make sure all other packages have been initialized
 // before calling them, which Load() does
-func Setup() {
+func Setup() error {
 	var err error
 
 	// load configuration
 	Configuration, err = Load()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to load configuration file, error: %s\n", err)
-		os.Exit(1)
+		return err
 	}
 
-	// load credentials
-	clientID, ok1 := Configuration["config"]["clientId"].(string)
-	accessToken, ok2 := Configuration["config"]["accessToken"].(string)
-	if ok1 && ok2 {
-		certificate, _ := Configuration["config"]["certificate"].(string)
-		authorizedScopes, _ := Configuration["config"]["authorizedScopes"].([]string)
-		Credentials = &client.Credentials{
-			ClientID:         clientID,
-			AccessToken:      accessToken,
-			Certificate:      certificate,
-			AuthorizedScopes: authorizedScopes,
-		}
+	// load root URL
+	RootURL, _ = Configuration["config"]["rootUrl"].(string)
+	return nil
+}
+
+// SetupCredentials loads the credentials of the active profile, after Setup,
+// for commands using credentials.
+func SetupCredentials() error {
+	var err error
+	Credentials, err = loadCredentials(Configuration)
+	if err != nil {
+		return err
+	}
+
+	// warn if temporary credentials have expired, or are about to
+	expiryWindow, _ := Configuration["config"]["certificateExpiryWarning"].(string)
+	window, _ := fromNow.Duration(expiryWindow)
+	if warning := expiryWarning(Credentials, window, time.Now()); warning != "" {
+		fmt.Fprintln(os.Stderr, warning)
 	}
+	return nil
 }
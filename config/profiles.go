@@ -0,0 +1,169 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+)
+
+// defaultProfile is the profile used unless another profile is selected,
+// other profiles inherit from it unless configured otherwise.
+const defaultProfile = "default"
+
+// Profile is the name of the profile selected with the --profile option, it
+// takes precedence over TASKCLUSTER_PROFILE and `config profile use`.
+var Profile string
+
+var profileNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// profile holds the configuration values set in a named profile.
+type profile struct {
+	// Inherits is the name of the profile values are inherited from, if
+	// empty the default profile.
+	Inherits string `yaml:"inherits,omitempty"`
+	// Config holds values by command and key
+	Config map[string]map[string]interface{} `yaml:",inline"`
+}
+
+// parent returns the name of the profile p inherits from, or "" if none.
+func (p *profile) parent(name string) string {
+	if p.Inherits != "" {
+		return p.Inherits
+	}
+	if name == defaultProfile {
+		return ""
+	}
+	return defaultProfile
+}
+
+// configData is the layout of the configuration file.
+type configData struct {
+	// Current is the profile selected with `config profile use`
+	Current  string              `yaml:"currentProfile,omitempty"`
+	Profiles map[string]*profile `yaml:"profiles"`
+}
+
+// activeProfile returns the name of the profile in use.
+func activeProfile(data *configData) string {
+	if Profile != "" {
+		return Profile
+	}
+	if name := os.Getenv("TASKCLUSTER_PROFILE"); name != "" {
+		return name
+	}
+	if data.Current != "" {
+		return data.Current
+	}
+	return defaultProfile
+}
+
+// profileChain returns the named profile preceded by the profiles it inherits
+// from, starting with the profile that inherits from nothing.
+func profileChain(data *configData, name string) ([]*profile, error) {
+	chain := []*profile{}
+	seen := make(map[string]bool)
+	for name != "" {
+		if seen[name] {
+			return nil, fmt.Errorf("Profile '%s' inherits from itself", name)
+		}
+		seen[name] = true
+		p, ok := data.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("No such profile: '%s'", name)
+		}
+		chain = append([]*profile{p}, chain...)
+		name = p.parent(name)
+	}
+	return chain, nil
+}
+
+// executeProfile implements the `config profile` subcommands.
+func executeProfile(argv map[string]interface{}) bool {
+	data, err := readConfigFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration file, error: %s\n", err)
+		return false
+	}
+	name, _ := argv["<name>"].(string)
+
+	switch {
+	case argv["list"] == true:
+		names := []string{}
+		for name := range data.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		active := activeProfile(data)
+		for _, name := range names {
+			marker := " "
+			if name == active {
+				marker = "*"
+			}
+			if parent := data.Profiles[name].parent(name); parent != "" {
+				fmt.Printf("%s %s (inherits %s)\n", marker, name, parent)
+			} else {
+				fmt.Printf("%s %s\n", marker, name)
+			}
+		}
+		return true
+
+	case argv["create"] == true:
+		if !profileNamePattern.MatchString(name) {
+			fmt.Fprintf(os.Stderr, "Invalid profile name: '%s', must consist of letters, digits, '-' and '_'\n", name)
+			return false
+		}
+		if _, ok := data.Profiles[name]; ok {
+			fmt.Fprintf(os.Stderr, "Profile '%s' already exists\n", name)
+			return false
+		}
+		p := &profile{}
+		if parent, ok := argv["--inherits"].(string); ok && parent != defaultProfile {
+			if _, ok := data.Profiles[parent]; !ok {
+				fmt.Fprintf(os.Stderr, "No such profile: '%s'\n", parent)
+				return false
+			}
+			p.Inherits = parent
+		}
+		data.Profiles[name] = p
+		fmt.Fprintf(os.Stderr, "Created profile '%s'\n", name)
+
+	case argv["use"] == true:
+		if _, ok := data.Profiles[name]; !ok {
+			fmt.Fprintf(os.Stderr, "No such profile: '%s'\n", name)
+			return false
+		}
+		data.Current = name
+		if name == defaultProfile {
+			data.Current = ""
+		}
+		fmt.Fprintf(os.Stderr, "Using profile '%s'\n", name)
+
+	case argv["delete"] == true:
+		if name == defaultProfile {
+			fmt.Fprintf(os.Stderr, "The default profile cannot be deleted\n")
+			return false
+		}
+		if _, ok := data.Profiles[name]; !ok {
+			fmt.Fprintf(os.Stderr, "No such profile: '%s'\n", name)
+			return false
+		}
+		for child, p := range data.Profiles {
+			if p.Inherits == name {
+				fmt.Fprintf(os.Stderr, "Profile '%s' cannot be deleted, '%s' inherits from it\n", name, child)
+				return false
+			}
+		}
+		delete(data.Profiles, name)
+		if data.Current == name {
+			data.Current = ""
+		}
+		fmt.Fprintf(os.Stderr, "Deleted profile '%s'\n", name)
+	}
+
+	if err := writeConfigFile(data); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save configuration file, error: %s\n", err)
+		return false
+	}
+	return true
+}
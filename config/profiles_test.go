@@ -0,0 +1,140 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// withConfigFile runs f with the configuration file holding content.
+func withConfigFile(t *testing.T, content string, f func()) {
+	dir, err := ioutil.TempDir("", "taskcluster-config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	for _, env := range []string{"TASKCLUSTER_PROFILE", "TASKCLUSTER_CLIENT_ID", "TASKCLUSTER_ACCESS_TOKEN", "TASKCLUSTER_ROOT_URL"} {
+		defer os.Setenv(env, os.Getenv(env))
+		os.Unsetenv(env)
+	}
+	defer func() { Profile = "" }()
+
+	if content != "" {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "taskcluster.yml"), []byte(content), 0600))
+	}
+	f()
+}
+
+func readLayout(t *testing.T) map[string]interface{} {
+	raw, err := ioutil.ReadFile(configFile())
+	assert.NoError(t, err)
+	var layout map[string]interface{}
+	assert.NoError(t, yaml.Unmarshal(raw, &layout))
+	return layout
+}
+
+func TestLoadMigratesOldLayout(t *testing.T) {
+	withConfigFile(t, "config:\n  clientId: tester\n", func() {
+		assert := assert.New(t)
+
+		config, err := Load()
+		assert.NoError(err)
+		assert.Equal("tester", config["config"]["clientId"])
+		assert.Equal("", config["config"]["accessToken"])
+
+		assert.NoError(Save(config))
+		layout := readLayout(t)
+		assert.Contains(layout, "profiles")
+		assert.NotContains(layout, "config")
+
+		config, err = Load()
+		assert.NoError(err)
+		assert.Equal("tester", config["config"]["clientId"])
+	})
+}
+
+func TestProfileInheritance(t *testing.T) {
+	content := `
+currentProfile: staging
+profiles:
+  default:
+    config:
+      clientId: tester
+      accessToken: secret
+  staging:
+    config:
+      rootUrl: https://tc.example.com
+  dev:
+    inherits: staging
+    config:
+      clientId: developer
+`
+	withConfigFile(t, content, func() {
+		assert := assert.New(t)
+
+		config, err := Load()
+		assert.NoError(err)
+		assert.Equal("tester", config["config"]["clientId"])
+		assert.Equal("https://tc.example.com", config["config"]["rootUrl"])
+
+		Profile = "dev"
+		config, err = Load()
+		assert.NoError(err)
+		assert.Equal("developer", config["config"]["clientId"])
+		assert.Equal("secret", config["config"]["accessToken"])
+		assert.Equal("https://tc.example.com", config["config"]["rootUrl"])
+
+		// Only values differing from the inherited ones are saved
+		config["config"]["accessToken"] = "other"
+		assert.NoError(Save(config))
+		data, err := readConfigFile()
+		assert.NoError(err)
+		assert.Equal(map[string]map[string]interface{}{
+			"config": {"clientId": "developer", "accessToken": "other"},
+		}, data.Profiles["dev"].Config)
+		assert.Equal("staging", data.Profiles["dev"].Inherits)
+
+		Profile = "missing"
+		_, err = Load()
+		assert.Error(err)
+	})
+}
+
+func TestProfileChainCycle(t *testing.T) {
+	data := &configData{Profiles: map[string]*profile{
+		"default": {},
+		"a":       {Inherits: "b"},
+		"b":       {Inherits: "a"},
+	}}
+	_, err := profileChain(data, "a")
+	assert.Error(t, err)
+
+	chain, err := profileChain(data, "default")
+	assert.NoError(t, err)
+	assert.Len(t, chain, 1)
+}
+
+func TestSetupCredentials(t *testing.T) {
+	content := `
+profiles:
+  default:
+    config:
+      credentialStore: helper
+      credentialHelper: "false"
+`
+	withConfigFile(t, content, func() {
+		assert := assert.New(t)
+		defer func() { Credentials = nil }()
+
+		// Credentials are only loaded when needed, and failing to load them
+		// is an error
+		assert.NoError(Setup())
+		assert.Nil(Credentials)
+		assert.Error(SetupCredentials())
+	})
+}
@@ -28,10 +28,8 @@ func configFile() string {
 	return filepath.Join(configFolder, "taskcluster.yml")
 }
 
-// Load will load confiration file, and initialize a default configuration
-// if no configuration is present. This only returns an error if a configuration
-// file is present, but we are unable to parse it.
-func Load() (map[string]map[string]interface{}, error) {
+// defaults returns the default values of all config options.
+func defaults() map[string]map[string]interface{} {
 	config := make(map[string]map[string]interface{})
 
 	// transfer everything from the providers
@@ -48,10 +46,43 @@ func Load() (map[string]map[string]interface{}, error) {
 			config[name][key] = option.Default
 		}
 	}
+	return config
+}
+
+// merge copies values into config, overwriting existing values.
+func merge(config, values map[string]map[string]interface{}) {
+	for name, options := range values {
+		if config[name] == nil {
+			config[name] = make(map[string]interface{})
+		}
+		for key, value := range options {
+			config[name][key] = value
+		}
+	}
+}
+
+// readConfigFile reads the configuration file, returning an empty
+// configuration if there is no file. Files written before profiles were
+// introduced are read as the configuration of the default profile.
+func readConfigFile() (*configData, error) {
+	data := &configData{}
+	raw, err := ioutil.ReadFile(configFile())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("Failed to read config file %s, error: %s", configFile(), err)
+	}
 
-	// Read config file and unmarshal into config overwriting default values
-	if data, err := ioutil.ReadFile(configFile()); err == nil {
-		if err = yaml.Unmarshal(data, &config); err != nil {
+	if err == nil {
+		var layout map[string]interface{}
+		if err = yaml.Unmarshal(raw, &layout); err == nil {
+			if _, ok := layout["profiles"]; ok {
+				err = yaml.Unmarshal(raw, data)
+			} else {
+				p := &profile{}
+				err = yaml.Unmarshal(raw, &p.Config)
+				data.Profiles = map[string]*profile{defaultProfile: p}
+			}
+		}
+		if err != nil {
 			return nil, fmt.Errorf(
 				"Read config file %s, but failed to parse YAML, error: %s",
 				configFile(), err,
@@ -59,6 +90,60 @@ func Load() (map[string]map[string]interface{}, error) {
 		}
 	}
 
+	// Ensure the default profile exists and no profile is nil
+	if data.Profiles == nil {
+		data.Profiles = make(map[string]*profile)
+	}
+	if data.Profiles[defaultProfile] == nil {
+		data.Profiles[defaultProfile] = &profile{}
+	}
+	for name, p := range data.Profiles {
+		if p == nil {
+			data.Profiles[name] = &profile{}
+		}
+	}
+	return data, nil
+}
+
+// writeConfigFile writes data to the configuration file.
+func writeConfigFile(data *configData) error {
+	// Serialize the config data
+	raw, err := yaml.Marshal(data)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to serialize configFile, error: %s", err))
+	}
+
+	// Write config file, readable only by the user as it may hold credentials
+	if err = ioutil.WriteFile(configFile(), raw, 0600); err != nil {
+		return fmt.Errorf("Failed to write config file: %s, error: %s", configFile(), err)
+	}
+	if err = os.Chmod(configFile(), 0600); err != nil {
+		return fmt.Errorf("Failed to restrict permissions of config file: %s, error: %s", configFile(), err)
+	}
+
+	return nil
+}
+
+// Load will load confiration file, and initialize a default configuration
+// if no configuration is present. This only returns an error if a configuration
+// file is present, but we are unable to parse it, or the active profile
+// doesn't exist.
+func Load() (map[string]map[string]interface{}, error) {
+	config := defaults()
+
+	// Read config file and apply the active profile, and the profiles it
+	// inherits from, overwriting default values
+	data, err := readConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	chain, err := profileChain(data, activeProfile(data))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range chain {
+		merge(config, p.Config)
+	}
 	// Load values from environment variables when applicable
 	for name, options := range OptionsDefinitions {
 		for key, option := range options {
@@ -116,23 +201,32 @@ func Load() (map[string]map[string]interface{}, error) {
 	return config, nil
 }
 
-// Save will save configuration.
+// Save will save configuration to the active profile. Only values that differ
+// from the values the profile inherits are saved.
 func Save(config map[string]map[string]interface{}) error {
-	result := make(map[string]map[string]interface{})
+	data, err := readConfigFile()
+	if err != nil {
+		return err
+	}
+	chain, err := profileChain(data, activeProfile(data))
+	if err != nil {
+		return err
+	}
 
-	// transfer everything from the providers
-	// into the new ConfigOptions object
-	// temporary for "transition"
-	for name, provider := range extpoints.CommandProviders() {
-		RegisterFromProvider(name, provider.ConfigOptions())
+	// Find the values inherited by the active profile
+	inherited := defaults()
+	for _, p := range chain[:len(chain)-1] {
+		merge(inherited, p.Config)
 	}
 
+	result := make(map[string]map[string]interface{})
+
 	// go over new object
 	for name, options := range OptionsDefinitions {
 		for key, option := range options {
 			value := config[name][key]
-			// Skip default values, no need to save those
-			if reflect.DeepEqual(value, option.Default) {
+			// Skip inherited values, no need to save those
+			if reflect.DeepEqual(value, inherited[name][key]) {
 				continue
 			}
 
@@ -157,16 +251,6 @@ func Save(config map[string]map[string]interface{}) error {
 		}
 	}
 
-	// Serialize the config data
-	data, err := yaml.Marshal(result)
-	if err != nil {
-		panic(fmt.Sprintf("Failed to serialize configFile, error: %s", err))
-	}
-
-	// Write config file
-	if err = ioutil.WriteFile(configFile(), data, 0664); err != nil {
-		return fmt.Errorf("Failed to write config file: %s, error: %s", configFile(), err)
-	}
-
-	return nil
+	chain[len(chain)-1].Config = result
+	return writeConfigFile(data)
 }
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/bryanl/webbrowser"
+	"github.com/taskcluster/taskcluster-cli/client"
 	"github.com/taskcluster/taskcluster-cli/extpoints"
 	graceful "gopkg.in/tylerb/graceful.v1"
 )
@@ -79,15 +80,15 @@ func (signin) Execute(context extpoints.Context) bool {
 	var serr error
 	s.Server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		qs := r.URL.Query()
-		config["config"]["clientId"] = qs.Get("clientId")
-		config["config"]["accessToken"] = qs.Get("accessToken")
-		config["config"]["certificate"] = qs.Get("certificate")
-
-		serr = Save(config)
+		serr = storeCredentials(config, &client.Credentials{
+			ClientID:    qs.Get("clientId"),
+			AccessToken: qs.Get("accessToken"),
+			Certificate: qs.Get("certificate"),
+		})
 		if serr != nil {
-			fmt.Fprintf(os.Stderr, "Failed to save configuration, error: %s\n", serr)
+			fmt.Fprintf(os.Stderr, "Failed to save credentials, error: %s\n", serr)
 		} else {
-			fmt.Println("Credentials saved in configuration file.")
+			fmt.Println("Credentials saved.")
 		}
 
 		title := "Successful"
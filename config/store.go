@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/taskcluster/taskcluster-cli/client"
+	"github.com/taskcluster/taskcluster-cli/extpoints"
+)
+
+// A CredentialStore stores credentials by profile name.
+type CredentialStore interface {
+	// Get returns the credentials stored for profile, or nil if there are
+	// none.
+	Get(profile string) (*client.Credentials, error)
+	// Store saves credentials for profile, replacing existing credentials.
+	Store(profile string, credentials *client.Credentials) error
+	// Erase removes the credentials stored for profile, if any.
+	Erase(profile string) error
+}
+
+// credentialKeys are the config keys holding credentials in the config file.
+var credentialKeys = []string{"clientId", "accessToken", "certificate"}
+
+// credentialStoreOptions are the config options selecting the credential
+// store, part of the options for the config command.
+var credentialStoreOptions = map[string]extpoints.ConfigOption{
+	"credentialStore": extpoints.ConfigOption{
+		Description: "Where credentials are stored: 'config' (configuration file), 'file' (encrypted file) or 'helper' (credentialHelper command).",
+		Default:     "config",
+		Validate: func(value interface{}) error {
+			switch value {
+			case "config", "file", "helper":
+				return nil
+			}
+			return errors.New("Must be one of 'config', 'file' or 'helper'")
+		},
+	},
+	"credentialHelper": extpoints.ConfigOption{
+		Description: "Command storing credentials, called with 'get', 'store' or 'erase' and exchanging JSON on stdin/stdout.",
+		Default:     "",
+		Validate:    isString,
+	},
+}
+
+// NewCredentialStore returns the credential store selected in config.
+func NewCredentialStore(config map[string]map[string]interface{}) (CredentialStore, error) {
+	switch config["config"]["credentialStore"] {
+	case "file":
+		return newFileStore(credentialsFile()), nil
+	case "helper":
+		command, _ := config["config"]["credentialHelper"].(string)
+		if command == "" {
+			return nil, errors.New("config.credentialHelper must be set to use the 'helper' credential store")
+		}
+		return helperStore{command: command}, nil
+	}
+	return configStore{config: config}, nil
+}
+
+// loadCredentials returns the credentials for the active profile. Credentials
+// given as environment variables take precedence over the credential store.
+func loadCredentials(config map[string]map[string]interface{}) (*client.Credentials, error) {
+	var store CredentialStore = configStore{config: config}
+	if os.Getenv("TASKCLUSTER_CLIENT_ID") == "" || os.Getenv("TASKCLUSTER_ACCESS_TOKEN") == "" {
+		var err error
+		if store, err = NewCredentialStore(config); err != nil {
+			return nil, err
+		}
+	}
+
+	profile, err := currentProfile()
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := store.Get(profile)
+	if credentials == nil || err != nil {
+		return nil, err
+	}
+
+	// authorizedScopes is configured independently of the credential store
+	if scopes, ok := stringList(config["config"]["authorizedScopes"]); ok {
+		credentials.AuthorizedScopes = scopes
+	}
+	return credentials, nil
+}
+
+// storeCredentials saves credentials for the active profile in the configured
+// credential store. Credentials previously saved in the configuration file
+// are removed when another store is used.
+func storeCredentials(config map[string]map[string]interface{}, credentials *client.Credentials) error {
+	store, err := NewCredentialStore(config)
+	if err != nil {
+		return err
+	}
+	profile, err := currentProfile()
+	if err != nil {
+		return err
+	}
+	if err := store.Store(profile, credentials); err != nil {
+		return err
+	}
+
+	if _, ok := store.(configStore); ok {
+		return nil
+	}
+	options := (cfg{}).ConfigOptions()
+	changed := false
+	for _, key := range credentialKeys {
+		if config["config"][key] != options[key].Default {
+			config["config"][key] = options[key].Default
+			changed = true
+		}
+	}
+	if changed {
+		return Save(config)
+	}
+	return nil
+}
+
+// configStore stores credentials in the configuration file, config holds the
+// loaded configuration.
+type configStore struct {
+	config map[string]map[string]interface{}
+}
+
+func (s configStore) Get(profile string) (*client.Credentials, error) {
+	clientID, ok1 := s.config["config"]["clientId"].(string)
+	accessToken, ok2 := s.config["config"]["accessToken"].(string)
+	if !ok1 || !ok2 || clientID == "" {
+		return nil, nil
+	}
+	certificate, _ := s.config["config"]["certificate"].(string)
+	return &client.Credentials{
+		ClientID:    clientID,
+		AccessToken: accessToken,
+		Certificate: certificate,
+	}, nil
+}
+
+func (s configStore) Store(profile string, credentials *client.Credentials) error {
+	s.config["config"]["clientId"] = credentials.ClientID
+	s.config["config"]["accessToken"] = credentials.AccessToken
+	s.config["config"]["certificate"] = credentials.Certificate
+	return Save(s.config)
+}
+
+func (s configStore) Erase(profile string) error {
+	options := (cfg{}).ConfigOptions()
+	for _, key := range credentialKeys {
+		s.config["config"][key] = options[key].Default
+	}
+	return Save(s.config)
+}
+
+// currentProfile returns the name of the active profile.
+func currentProfile() (string, error) {
+	data, err := readConfigFile()
+	if err != nil {
+		return "", fmt.Errorf("Failed to load configuration file, error: %s", err)
+	}
+	return activeProfile(data), nil
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster-cli/client"
+)
+
+func TestPBKDF2(t *testing.T) {
+	assert := assert.New(t)
+
+	// Test vectors for PBKDF2-HMAC-SHA256
+	assert.Equal(
+		"120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b",
+		hex.EncodeToString(pbkdf2([]byte("password"), []byte("salt"), 1, 32)),
+	)
+	assert.Equal(
+		"ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43",
+		hex.EncodeToString(pbkdf2([]byte("password"), []byte("salt"), 2, 32)),
+	)
+}
+
+func TestFileStore(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "taskcluster-credentials")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "credentials")
+
+	prompts := 0
+	store := &fileStore{path: path, passphrase: func() (string, error) {
+		prompts++
+		return "secret", nil
+	}}
+	creds := &client.Credentials{ClientID: "tester", AccessToken: "no-secret"}
+
+	c, err := store.Get("default")
+	assert.NoError(err)
+	assert.Nil(c)
+
+	assert.NoError(store.Store("default", creds))
+	assert.NoError(store.Store("staging", &client.Credentials{ClientID: "staging"}))
+	assert.Equal(1, prompts)
+
+	info, err := os.Stat(path)
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0600), info.Mode().Perm())
+	raw, err := ioutil.ReadFile(path)
+	assert.NoError(err)
+	assert.NotContains(string(raw), "no-secret")
+
+	c, err = (&fileStore{path: path, passphrase: func() (string, error) { return "secret", nil }}).Get("default")
+	assert.NoError(err)
+	assert.Equal(creds, c)
+
+	_, err = (&fileStore{path: path, passphrase: func() (string, error) { return "wrong", nil }}).Get("default")
+	assert.Error(err)
+
+	assert.NoError(store.Erase("default"))
+	c, err = store.Get("default")
+	assert.NoError(err)
+	assert.Nil(c)
+	c, err = store.Get("staging")
+	assert.NoError(err)
+	assert.Equal("staging", c.ClientID)
+}
+
+func TestHelperStore(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "taskcluster-helper")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// A helper saving the request for 'store' and returning it for 'get'
+	helper := filepath.Join(dir, "helper")
+	saved := filepath.Join(dir, "saved")
+	script := "#!/bin/sh\n" +
+		"case \"$1\" in\n" +
+		"  store) cat > " + saved + " ;;\n" +
+		"  get) [ -f " + saved + " ] && sed 's/.*\"credentials\"://; s/}$//' " + saved + " ;;\n" +
+		"  erase) rm -f " + saved + " ;;\n" +
+		"esac\n" +
+		"exit 0\n"
+	assert.NoError(ioutil.WriteFile(helper, []byte(script), 0700))
+	store := helperStore{command: helper}
+
+	c, err := store.Get("default")
+	assert.NoError(err)
+	assert.Nil(c)
+
+	creds := &client.Credentials{ClientID: "tester", AccessToken: "no-secret"}
+	assert.NoError(store.Store("default", creds))
+	c, err = store.Get("default")
+	assert.NoError(err)
+	assert.Equal(creds, c)
+
+	assert.NoError(store.Erase("default"))
+	c, err = store.Get("default")
+	assert.NoError(err)
+	assert.Nil(c)
+
+	assert.Error(helperStore{command: filepath.Join(dir, "missing")}.Store("default", creds))
+}
@@ -20,8 +20,22 @@ type Context struct {
 	Arguments map[string]interface{}
 	// Globally configured taskcluster credentials (nil, if none are available)
 	Credentials *client.Credentials
+	// Root URL of the taskcluster deployment, see client.ServiceURL
+	RootURL string
 	// Config keys matching declared ConfigOptions
 	Config map[string]interface{}
+	// Exit code if Execute returns false, set with Fail (nil, if not used)
+	ExitCode *int
+}
+
+// Fail sets the exit code of the command to code and returns false, for
+// failures that scripts must be able to tell apart, e.g.
+// 'return context.Fail(3)'.
+func (c Context) Fail(code int) bool {
+	if c.ExitCode != nil {
+		*c.ExitCode = code
+	}
+	return false
 }
 
 // CommandProvider is implemented by anyone who wishes to provide a command line
@@ -36,3 +50,10 @@ type CommandProvider interface {
 	// Execute is called with parsed docopt result in Context
 	Execute(context Context) bool
 }
+
+// CredentialsUser is implemented by CommandProviders that use
+// Context.Credentials. Credentials are only loaded for those commands, as
+// loading them may prompt for a passphrase.
+type CredentialsUser interface {
+	UsesCredentials() bool
+}
@@ -0,0 +1,201 @@
+// Package format renders JSON values for display as JSON, YAML or as an
+// aligned table.
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Parse decodes JSON data into a value suitable for rendering, numbers are
+// kept as int64 where possible so they don't lose precision.
+func Parse(data []byte) (interface{}, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return normalize(value), nil
+}
+
+// normalize replaces json.Number values with int64 or float64.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, val := range v {
+			v[key] = normalize(val)
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = normalize(val)
+		}
+	}
+	return value
+}
+
+// Format renders value in the named format.
+func Format(name string, value interface{}) ([]byte, error) {
+	switch name {
+	case "json":
+		return JSON(value)
+	case "yaml":
+		return YAML(value)
+	case "table":
+		return Table(value)
+	}
+	return nil, fmt.Errorf("unsupported output format: %s", name)
+}
+
+// JSON renders value as indented JSON.
+func JSON(value interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// YAML renders value as YAML.
+func YAML(value interface{}) ([]byte, error) {
+	return yaml.Marshal(value)
+}
+
+// Table renders value as aligned columns. Arrays of objects are rendered
+// with one row per item and one column per (flattened) key. An object
+// holding a single array of objects, as list responses do, is rendered as
+// that array. Any other object is rendered as a key/value table.
+func Table(value interface{}) ([]byte, error) {
+	if obj, ok := value.(map[string]interface{}); ok {
+		if rows := objectArray(obj); rows != nil {
+			value = rows
+		}
+	}
+
+	var rows []map[string]string
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			row := make(map[string]string)
+			if obj, ok := item.(map[string]interface{}); ok {
+				flatten("", obj, row)
+			} else {
+				row["value"] = cell(item)
+			}
+			rows = append(rows, row)
+		}
+	case map[string]interface{}:
+		row := make(map[string]string)
+		flatten("", v, row)
+		keys := sortedKeys(row)
+		buf := &bytes.Buffer{}
+		w := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s\t%s\n", key, row[key])
+		}
+		w.Flush()
+		return buf.Bytes(), nil
+	default:
+		return []byte(cell(value) + "\n"), nil
+	}
+
+	// Collect columns from all rows
+	seen := make(map[string]bool)
+	columns := []string{}
+	for _, row := range rows {
+		for key := range row {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		}
+	}
+	sort.Strings(columns)
+
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = strings.ToUpper(column)
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			cells[i] = row[column]
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	w.Flush()
+	return buf.Bytes(), nil
+}
+
+// objectArray returns the array held by obj, if obj has exactly one property
+// that is an array of objects, and nil otherwise.
+func objectArray(obj map[string]interface{}) []interface{} {
+	var result []interface{}
+	for _, value := range obj {
+		list, ok := value.([]interface{})
+		if !ok {
+			continue
+		}
+		for _, item := range list {
+			if _, ok := item.(map[string]interface{}); !ok {
+				return nil
+			}
+		}
+		if result != nil {
+			return nil
+		}
+		result = list
+	}
+	return result
+}
+
+// flatten stores the values of obj in row, using dotted keys for nested
+// objects.
+func flatten(prefix string, obj map[string]interface{}, row map[string]string) {
+	for key, value := range obj {
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			flatten(prefix+key+".", nested, row)
+		} else {
+			row[prefix+key] = cell(value)
+		}
+	}
+}
+
+// cell renders a single value for a table cell.
+func cell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.Replace(v, "\n", " ", -1)
+	case []interface{}, map[string]interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
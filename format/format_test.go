@@ -0,0 +1,61 @@
+package format
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+
+	value, err := Parse([]byte(`{"big": 9007199254740993, "ratio": 0.5}`))
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{
+		"big":   int64(9007199254740993),
+		"ratio": 0.5,
+	}, value)
+}
+
+func TestTableArray(t *testing.T) {
+	assert := assert.New(t)
+
+	value, _ := Parse([]byte(`{"taskGroupId": "abc", "tasks": [
+		{"status": {"taskId": "a", "state": "completed"}},
+		{"status": {"taskId": "bb", "state": "failed"}, "extra": [1, 2]}
+	]}`))
+	data, err := Table(value)
+	assert.NoError(err)
+	assert.Equal(""+
+		"EXTRA  STATUS.STATE  STATUS.TASKID\n"+
+		"       completed     a\n"+
+		"[1,2]  failed        bb\n", string(data))
+}
+
+func TestTableObject(t *testing.T) {
+	assert := assert.New(t)
+
+	value, _ := Parse([]byte(`{"state": "running", "runs": [1], "meta": {"name": "x"}}`))
+	data, err := Table(value)
+	assert.NoError(err)
+	assert.Equal(""+
+		"meta.name  x\n"+
+		"runs       [1]\n"+
+		"state      running\n", string(data))
+}
+
+func TestFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	value, _ := Parse([]byte(`{"a": 1}`))
+	data, err := Format("yaml", value)
+	assert.NoError(err)
+	assert.Equal("a: 1\n", string(data))
+
+	data, err = Format("json", value)
+	assert.NoError(err)
+	assert.Equal("{\n  \"a\": 1\n}\n", string(data))
+
+	_, err = Format("xml", value)
+	assert.Error(err)
+}
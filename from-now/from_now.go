@@ -34,25 +34,44 @@ func (fromNow) Usage() string {
 func (fromNow) Execute(context extpoints.Context) bool {
 	duration := context.Arguments["<duration>"].(string)
 
-	offset, err := parseTime(duration)
+	timein, err := Add(time.Now(), duration)
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: string '%s' is not a valid time expression\n", duration)
 		return false
 	}
 
+	fmt.Println(timein.Format(time.RFC3339))
+
+	return true
+}
+
+// Add returns the time which is duration ahead of t, where duration is a
+// time expression as accepted by parseTime, e.g. '2 hours' or '1d2h'.
+func Add(t time.Time, duration string) (time.Time, error) {
+	offset, err := parseTime(duration)
+	if err != nil {
+		return time.Time{}, err
+	}
+
 	timeToAdd := time.Hour*time.Duration(offset.weeks*7*24) +
 		time.Hour*time.Duration(offset.days*24) +
 		time.Hour*time.Duration(offset.hours) +
 		time.Minute*time.Duration(offset.minutes) +
 		time.Second*time.Duration(offset.seconds)
 
-	timein := time.Now().Add(timeToAdd)
-	timein = timein.AddDate(offset.years, offset.months, 0)
-
-	fmt.Println(timein.Format(time.RFC3339))
+	return t.Add(timeToAdd).AddDate(offset.years, offset.months, 0), nil
+}
 
-	return true
+// Duration returns the duration of the time expression duration from now, as
+// accepted by Add, e.g. '90 seconds'.
+func Duration(duration string) (time.Duration, error) {
+	now := time.Now()
+	t, err := Add(now, duration)
+	if err != nil {
+		return 0, err
+	}
+	return t.Sub(now), nil
 }
 
 type timeOffset struct {
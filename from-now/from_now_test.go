@@ -2,6 +2,7 @@ package fromNow
 
 import (
 	"testing"
+	"time"
 
 	assert "github.com/stretchr/testify/require"
 )
@@ -44,6 +45,18 @@ func TestParseTimeInvalid(t *testing.T) {
 	assert.Error(err, "the string should produce an error")
 }
 
+func TestAdd(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2017, 1, 31, 12, 0, 0, 0, time.UTC)
+	result, err := Add(now, "1 month 2 hours")
+	assert.NoError(err)
+	assert.Equal(time.Date(2017, 3, 3, 14, 0, 0, 0, time.UTC), result)
+
+	_, err = Add(now, "tomorrow")
+	assert.Error(err)
+}
+
 func TestAtoiHelper(t *testing.T) {
 	assert := assert.New(t)
 
@@ -55,3 +68,14 @@ func TestAtoiHelper(t *testing.T) {
 		atoiHelper("!")
 	}, "should panic")
 }
+
+func TestDuration(t *testing.T) {
+	assert := assert.New(t)
+
+	d, err := Duration("1 hour 30 minutes")
+	assert.NoError(err)
+	assert.Equal(90*time.Minute, d)
+
+	_, err = Duration("90m")
+	assert.Error(err)
+}
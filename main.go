@@ -52,6 +52,7 @@ func main() {
 	usage += "  --client-id <clientId>        ClientId [default: TASKCLUSTER_CLIENT_ID]\n"
 	usage += "  --access-token <accessToken>  AccessToken [default: TASKCLUSTER_ACCESS_TOKEN]\n"
 	usage += "  --certificate <certificate>   Certificate [default: TASKCLUSTER_CERTIFICATE]\n"
+	usage += "  --profile <profile>           Configuration profile to use\n"
 	usage += "\n"
 
 	// Parse arguments
@@ -81,19 +82,35 @@ func main() {
 		true, version.VersionNumber, false,
 	)
 
-	// set up the whole config thing
-	config.Setup()
+	// set up the whole config thing, the config command loads the
+	// configuration itself, so profiles can be created and repaired
+	config.Profile, _ = arguments["--profile"].(string)
+	if err := config.Setup(); err != nil && cmd != "config" {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration file, error: %s\n", err)
+		os.Exit(1)
+	}
+
+	// load credentials only for commands using them
+	if user, ok := provider.(extpoints.CredentialsUser); ok && user.UsesCredentials() {
+		if err := config.SetupCredentials(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load credentials, error: %s\n", err)
+			os.Exit(1)
+		}
+	}
 
 	// Execute provider with parsed args
+	exitCode := 1
 	success := provider.Execute(extpoints.Context{
 		Arguments:   subArguments,
 		Config:      config.Configuration[cmd],
 		Credentials: config.Credentials,
+		RootURL:     config.RootURL,
+		ExitCode:    &exitCode,
 	})
 
 	if success {
 		os.Exit(0)
 	} else {
-		os.Exit(1)
+		os.Exit(exitCode)
 	}
 }
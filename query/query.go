@@ -0,0 +1,283 @@
+// Package query implements a small path expression language for selecting
+// values from JSON documents, similar to a subset of jq.
+//
+// An expression is a sequence of steps, each selecting from the result of the
+// previous step:
+//
+//   .key or key    value of an object key
+//   ["key"]        value of an object key, for keys with special characters
+//   [n]            n'th element of an array, negative n counts from the end
+//   [a:b]          slice of an array, either bound may be omitted
+//   [] or [*]      every element of an array, or value of an object
+//   .*             same as []
+//
+// For example, 'status.runs[-1].state' selects the state of the last run,
+// and 'tasks[].status.taskId' selects the taskId of every task.
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type stepKind int
+
+const (
+	stepKey stepKind = iota
+	stepIndex
+	stepSlice
+	stepAll
+)
+
+type step struct {
+	kind     stepKind
+	key      string
+	index    int
+	from, to *int
+}
+
+// Expression is a compiled query expression.
+type Expression struct {
+	source string
+	steps  []step
+}
+
+// Parse compiles a query expression.
+func Parse(expr string) (*Expression, error) {
+	e := &Expression{source: expr}
+	s := strings.TrimSpace(expr)
+	if s == "." || s == "" {
+		return e, nil
+	}
+
+	// Allow the first key to be given without a leading dot
+	if s[0] != '.' && s[0] != '[' {
+		s = "." + s
+	}
+
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			if strings.HasPrefix(s, "*") {
+				e.steps = append(e.steps, step{kind: stepAll})
+				s = s[1:]
+				continue
+			}
+			n := 0
+			for n < len(s) && isIdentChar(s[n]) {
+				n++
+			}
+			if n == 0 {
+				// Allow '.[0]' as in jq
+				if strings.HasPrefix(s, "[") {
+					continue
+				}
+				return nil, fmt.Errorf("expected key after '.' in '%s'", expr)
+			}
+			e.steps = append(e.steps, step{kind: stepKey, key: s[:n]})
+			s = s[n:]
+		case '[':
+			st, rest, err := parseBracket(s[1:])
+			if err != nil {
+				return nil, fmt.Errorf("%s in '%s'", err, expr)
+			}
+			e.steps = append(e.steps, st)
+			s = rest
+		default:
+			return nil, fmt.Errorf("unexpected '%c' in '%s'", s[0], expr)
+		}
+	}
+	return e, nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '-' || c == '$' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// parseBracket parses the contents of '[...]', s starts after the '['.
+func parseBracket(s string) (step, string, error) {
+	// Quoted key
+	if len(s) > 0 && (s[0] == '"' || s[0] == '\'') {
+		quote := s[0]
+		end := strings.IndexByte(s[1:], quote)
+		if end == -1 || !strings.HasPrefix(s[end+2:], "]") {
+			return step{}, "", fmt.Errorf("unterminated key")
+		}
+		return step{kind: stepKey, key: s[1 : end+1]}, s[end+3:], nil
+	}
+
+	end := strings.IndexByte(s, ']')
+	if end == -1 {
+		return step{}, "", fmt.Errorf("missing ']'")
+	}
+	inner := strings.TrimSpace(s[:end])
+	rest := s[end+1:]
+
+	if inner == "" || inner == "*" {
+		return step{kind: stepAll}, rest, nil
+	}
+	if parts := strings.SplitN(inner, ":", 2); len(parts) == 2 {
+		st := step{kind: stepSlice}
+		for i, part := range parts {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return step{}, "", fmt.Errorf("invalid slice bound '%s'", part)
+			}
+			if i == 0 {
+				st.from = &n
+			} else {
+				st.to = &n
+			}
+		}
+		return st, rest, nil
+	}
+	n, err := strconv.Atoi(inner)
+	if err != nil {
+		return step{}, "", fmt.Errorf("invalid index '%s'", inner)
+	}
+	return step{kind: stepIndex, index: n}, rest, nil
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Evaluate applies the expression to value, which must be a decoded JSON
+// document. It returns the list of selected values, which has exactly one
+// element unless the expression iterates with '[]'. Missing keys and
+// indexes out of range select null.
+func (e *Expression) Evaluate(value interface{}) ([]interface{}, error) {
+	results := []interface{}{value}
+	for _, st := range e.steps {
+		next := []interface{}{}
+		for _, v := range results {
+			selected, err := st.apply(v)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, selected...)
+		}
+		results = next
+	}
+	return results, nil
+}
+
+func (st step) apply(value interface{}) ([]interface{}, error) {
+	if value == nil {
+		if st.kind == stepAll {
+			return nil, nil
+		}
+		return []interface{}{nil}, nil
+	}
+
+	switch st.kind {
+	case stepKey:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot select key '%s' from %s", st.key, typeName(value))
+		}
+		return []interface{}{obj[st.key]}, nil
+	case stepIndex:
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot index %s with %d", typeName(value), st.index)
+		}
+		i := st.index
+		if i < 0 {
+			i += len(list)
+		}
+		if i < 0 || i >= len(list) {
+			return []interface{}{nil}, nil
+		}
+		return []interface{}{list[i]}, nil
+	case stepSlice:
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot slice %s", typeName(value))
+		}
+		from, to := 0, len(list)
+		if st.from != nil {
+			from = clamp(*st.from, len(list))
+		}
+		if st.to != nil {
+			to = clamp(*st.to, len(list))
+		}
+		if to < from {
+			to = from
+		}
+		return []interface{}{list[from:to]}, nil
+	case stepAll:
+		switch v := value.(type) {
+		case []interface{}:
+			return v, nil
+		case map[string]interface{}:
+			keys := []string{}
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			values := []interface{}{}
+			for _, key := range keys {
+				values = append(values, v[key])
+			}
+			return values, nil
+		}
+		return nil, fmt.Errorf("cannot iterate over %s", typeName(value))
+	}
+	panic("internal error: unknown step kind")
+}
+
+// clamp resolves a possibly negative slice bound against length n.
+func clamp(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	return "number"
+}
+
+// Render renders results one per line, strings are written without quotes
+// and other values as compact JSON.
+func Render(results []interface{}) []byte {
+	buf := &bytes.Buffer{}
+	for _, result := range results {
+		if s, ok := result.(string); ok {
+			buf.WriteString(s)
+		} else {
+			data, _ := json.Marshal(result)
+			buf.Write(data)
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,73 @@
+package query
+
+import (
+	"encoding/json"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+var document = `{
+	"status": {
+		"taskId": "abc",
+		"runs": [
+			{"runId": 0, "state": "failed"},
+			{"runId": 1, "state": "completed"}
+		]
+	},
+	"weird.key": 1
+}`
+
+func evaluate(t *testing.T, expr string) []interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(document), &value); err != nil {
+		t.Fatal(err)
+	}
+	e, err := Parse(expr)
+	assert.NoError(t, err, "expression '%s' should parse", expr)
+	results, err := e.Evaluate(value)
+	assert.NoError(t, err, "expression '%s' should evaluate", expr)
+	return results
+}
+
+func TestEvaluate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]interface{}{"completed"}, evaluate(t, "status.runs[-1].state"))
+	assert.Equal([]interface{}{"completed"}, evaluate(t, ".status.runs[1].state"))
+	assert.Equal([]interface{}{"failed", "completed"}, evaluate(t, "status.runs[].state"))
+	assert.Equal([]interface{}{"failed", "completed"}, evaluate(t, "status.runs[*].state"))
+	assert.Equal([]interface{}{float64(1)}, evaluate(t, `["weird.key"]`))
+	assert.Equal([]interface{}{nil}, evaluate(t, "status.missing"))
+	assert.Equal([]interface{}{nil}, evaluate(t, "status.runs[5]"))
+	assert.Len(evaluate(t, "."), 1)
+	assert.Len(evaluate(t, ".status.*"), 2)
+
+	slice := evaluate(t, "status.runs[-1:]")
+	assert.Len(slice, 1)
+	assert.Len(slice[0], 1)
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, expr := range []string{"status[", "status.", "status..runs", "status[x]", `["a]`} {
+		_, err := Parse(expr)
+		assert.Error(err, "expression '%s' should not parse", expr)
+	}
+
+	e, err := Parse("status.taskId[0]")
+	assert.NoError(err)
+	_, err = e.Evaluate(map[string]interface{}{
+		"status": map[string]interface{}{"taskId": "abc"},
+	})
+	assert.Error(err)
+}
+
+func TestRender(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("abc\n{\"a\":1}\nnull\n", string(Render([]interface{}{
+		"abc", map[string]interface{}{"a": 1}, nil,
+	})))
+}
@@ -0,0 +1,247 @@
+// Package retry implements an http.RoundTripper that retries requests failing
+// with connection errors or 5xx responses, using exponential backoff with
+// jitter. Request bodies are replayed and requests can be re-signed on every
+// attempt, so each attempt carries a fresh signature.
+package retry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/taskcluster/taskcluster-cli/extpoints"
+	fromNow "github.com/taskcluster/taskcluster-cli/from-now"
+)
+
+// Options controls how requests are retried.
+type Options struct {
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries int
+	// Timeout is the total time after which no more retries are attempted,
+	// zero means no limit.
+	Timeout time.Duration
+	// InitialDelay is the delay before the first retry.
+	InitialDelay time.Duration
+	// MaxDelay is the upper bound for the delay between two attempts.
+	MaxDelay time.Duration
+	// Multiplier is the factor by which the delay grows between attempts.
+	Multiplier float64
+	// Jitter is the fraction by which each delay is randomized, e.g. 0.25
+	// gives a delay between 75% and 125% of the nominal delay.
+	Jitter float64
+}
+
+// DefaultOptions are the options used unless configured otherwise.
+var DefaultOptions = Options{
+	MaxRetries:   5,
+	Timeout:      2 * time.Minute,
+	InitialDelay: 100 * time.Millisecond,
+	MaxDelay:     30 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.25,
+}
+
+// Delay returns the delay before retry number attempt (starting at 1),
+// without jitter.
+func (o Options) Delay(attempt int) time.Duration {
+	d := float64(o.InitialDelay) * math.Pow(o.Multiplier, float64(attempt-1))
+	if o.MaxDelay > 0 && d > float64(o.MaxDelay) {
+		d = float64(o.MaxDelay)
+	}
+	return time.Duration(d)
+}
+
+// jittered randomizes d by the configured jitter.
+func (o Options) jittered(d time.Duration) time.Duration {
+	if o.Jitter <= 0 {
+		return d
+	}
+	delta := o.Jitter * float64(d)
+	return time.Duration(float64(d) - delta + rand.Float64()*2*delta)
+}
+
+// ConfigOptions returns the config options for retries, for commands to
+// include in their own ConfigOptions.
+func ConfigOptions() map[string]extpoints.ConfigOption {
+	return map[string]extpoints.ConfigOption{
+		"maxRetries": extpoints.ConfigOption{
+			Description: "Number of times to retry requests failing with connection errors or 5xx.",
+			Default:     DefaultOptions.MaxRetries,
+			Parse:       true,
+			Validate: func(value interface{}) error {
+				if _, err := toInt(value); err != nil {
+					return err
+				}
+				return nil
+			},
+		},
+		"retryTimeout": extpoints.ConfigOption{
+			Description: "Time after which failed requests are no longer retried, e.g. '90 seconds'.",
+			Default:     "2 minutes",
+			Validate: func(value interface{}) error {
+				s, ok := value.(string)
+				if !ok {
+					return errors.New("Must be a string")
+				}
+				_, err := fromNow.Duration(s)
+				return err
+			},
+		},
+	}
+}
+
+// FromContext returns the retry options configured for the command in
+// context, taking --max-retries and --retry-timeout arguments into account.
+func FromContext(context extpoints.Context) (Options, error) {
+	opts := DefaultOptions
+
+	maxRetries := context.Config["maxRetries"]
+	if s, ok := context.Arguments["--max-retries"].(string); ok {
+		maxRetries = s
+	}
+	if maxRetries != nil {
+		n, err := toInt(maxRetries)
+		if err != nil {
+			return opts, fmt.Errorf("invalid max retries: %s", err)
+		}
+		opts.MaxRetries = n
+	}
+
+	timeout, _ := context.Config["retryTimeout"].(string)
+	if s, ok := context.Arguments["--retry-timeout"].(string); ok {
+		timeout = s
+	}
+	if timeout != "" {
+		d, err := fromNow.Duration(timeout)
+		if err != nil {
+			return opts, fmt.Errorf("invalid retry timeout: %s", err)
+		}
+		opts.Timeout = d
+	}
+	return opts, nil
+}
+
+// toInt converts a number from config or command line arguments to int.
+func toInt(value interface{}) (int, error) {
+	var n int
+	switch v := value.(type) {
+	case int:
+		n = v
+	case float64:
+		n = int(v)
+		if float64(n) != v {
+			return 0, errors.New("Must be an integer")
+		}
+	case string:
+		var err error
+		if n, err = strconv.Atoi(v); err != nil {
+			return 0, errors.New("Must be an integer")
+		}
+	default:
+		return 0, errors.New("Must be an integer")
+	}
+	if n < 0 {
+		return 0, errors.New("Must not be negative")
+	}
+	return n, nil
+}
+
+// Transport is an http.RoundTripper that retries requests.
+type Transport struct {
+	// Base is the transport used for each attempt, http.DefaultTransport if
+	// nil.
+	Base http.RoundTripper
+	// Options controls the retries.
+	Options Options
+	// Sign is called with a copy of the request and its body before every
+	// attempt, if not nil. Requests created by following redirects are not
+	// signed.
+	Sign func(req *http.Request, body []byte) error
+
+	// sleep and now are used to wait between attempts and measure the time
+	// spent, replaced in tests.
+	sleep func(time.Duration)
+	now   func() time.Time
+}
+
+// NewClient returns an http.Client that retries requests with the given
+// options, signing every attempt with sign, if not nil.
+func NewClient(opts Options, sign func(req *http.Request, body []byte) error) *http.Client {
+	return &http.Client{
+		Transport: &Transport{Options: opts, Sign: sign},
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	sleep := t.sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	now := t.now
+	if now == nil {
+		now = time.Now
+	}
+
+	// Buffer the body, so it can be replayed for every attempt
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	start := now()
+	for attempt := 0; ; attempt++ {
+		// Copy the request, as a RoundTripper must not modify it
+		r := new(http.Request)
+		*r = *req
+		r.Header = make(http.Header, len(req.Header))
+		for k, v := range req.Header {
+			r.Header[k] = append([]string(nil), v...)
+		}
+		if body != nil {
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+		}
+
+		if t.Sign != nil && req.Response == nil {
+			if err := t.Sign(r, body); err != nil {
+				return nil, err
+			}
+		}
+
+		res, err := base.RoundTrip(r)
+		if err == nil && res.StatusCode/100 != 5 {
+			return res, nil
+		}
+
+		// Give up if we're out of retries or time
+		delay := t.Options.jittered(t.Options.Delay(attempt + 1))
+		if attempt >= t.Options.MaxRetries ||
+			(t.Options.Timeout > 0 && now().Sub(start)+delay > t.Options.Timeout) {
+			return res, err
+		}
+
+		// Discard the failed response, so the connection can be reused
+		if res != nil {
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+		sleep(delay)
+	}
+}
@@ -0,0 +1,190 @@
+package retry
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster-cli/extpoints"
+)
+
+// failingServer returns a server that fails the first n requests with 500
+// and records the body and Authorization header of every request.
+func failingServer(n int, bodies, signatures *[]string) *httptest.Server {
+	count := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		*bodies = append(*bodies, string(data))
+		*signatures = append(*signatures, r.Header.Get("Authorization"))
+		count++
+		if count <= n {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}))
+}
+
+func newTransport(opts Options, delays *[]time.Duration) *Transport {
+	attempt := 0
+	clock := time.Now()
+	return &Transport{
+		Options: opts,
+		Sign: func(req *http.Request, body []byte) error {
+			attempt++
+			req.Header.Set("Authorization", "attempt-"+string('0'+rune(attempt)))
+			return nil
+		},
+		sleep: func(d time.Duration) {
+			*delays = append(*delays, d)
+			clock = clock.Add(d)
+		},
+		now: func() time.Time {
+			return clock
+		},
+	}
+}
+
+func TestRetryReplaysBodyAndSignsEachAttempt(t *testing.T) {
+	assert := assert.New(t)
+
+	bodies, signatures := []string{}, []string{}
+	server := failingServer(2, &bodies, &signatures)
+	defer server.Close()
+
+	delays := []time.Duration{}
+	opts := DefaultOptions
+	opts.Jitter = 0
+	client := &http.Client{Transport: newTransport(opts, &delays)}
+
+	res, err := client.Post(server.URL, "application/json", bytes.NewBufferString(`{"a":1}`))
+	assert.NoError(err)
+	defer res.Body.Close()
+	assert.Equal(http.StatusOK, res.StatusCode)
+
+	assert.Equal([]string{`{"a":1}`, `{"a":1}`, `{"a":1}`}, bodies)
+	assert.Equal([]string{"attempt-1", "attempt-2", "attempt-3"}, signatures)
+	assert.Equal([]time.Duration{100 * time.Millisecond, 200 * time.Millisecond}, delays)
+}
+
+func TestRetryGivesUpAfterMaxRetries(t *testing.T) {
+	assert := assert.New(t)
+
+	bodies, signatures := []string{}, []string{}
+	server := failingServer(10, &bodies, &signatures)
+	defer server.Close()
+
+	delays := []time.Duration{}
+	opts := DefaultOptions
+	opts.MaxRetries = 2
+	client := &http.Client{Transport: newTransport(opts, &delays)}
+
+	res, err := client.Get(server.URL)
+	assert.NoError(err)
+	res.Body.Close()
+	assert.Equal(http.StatusInternalServerError, res.StatusCode)
+	assert.Len(bodies, 3)
+	assert.Len(delays, 2)
+}
+
+func TestRetryDoesNotRetryClientErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	delays := []time.Duration{}
+	client := &http.Client{Transport: newTransport(DefaultOptions, &delays)}
+
+	res, err := client.Get(server.URL)
+	assert.NoError(err)
+	res.Body.Close()
+	assert.Equal(http.StatusNotFound, res.StatusCode)
+	assert.Equal(1, count)
+	assert.Empty(delays)
+}
+
+func TestRetryConnectionErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	// Close the server, so that all connections are refused
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	delays := []time.Duration{}
+	opts := DefaultOptions
+	opts.MaxRetries = 3
+	client := &http.Client{Transport: newTransport(opts, &delays)}
+
+	_, err := client.Get(server.URL)
+	assert.Error(err)
+	assert.Len(delays, 3)
+}
+
+func TestRetryTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	bodies, signatures := []string{}, []string{}
+	server := failingServer(10, &bodies, &signatures)
+	defer server.Close()
+
+	delays := []time.Duration{}
+	opts := DefaultOptions
+	opts.Jitter = 0
+	opts.Timeout = 250 * time.Millisecond
+	client := &http.Client{Transport: newTransport(opts, &delays)}
+
+	res, err := client.Get(server.URL)
+	assert.NoError(err)
+	res.Body.Close()
+	// 100ms fits in the timeout, but 100ms + 200ms does not
+	assert.Equal([]time.Duration{100 * time.Millisecond}, delays)
+}
+
+func TestDelay(t *testing.T) {
+	assert := assert.New(t)
+
+	opts := Options{InitialDelay: time.Second, MaxDelay: 5 * time.Second, Multiplier: 2}
+	assert.Equal(time.Second, opts.Delay(1))
+	assert.Equal(4*time.Second, opts.Delay(3))
+	assert.Equal(5*time.Second, opts.Delay(10))
+
+	opts.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := opts.jittered(time.Second)
+		assert.True(d >= 500*time.Millisecond && d <= 1500*time.Millisecond)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	assert := assert.New(t)
+
+	opts, err := FromContext(extpoints.Context{
+		Arguments: map[string]interface{}{"--max-retries": "2"},
+		Config:    map[string]interface{}{"maxRetries": float64(7), "retryTimeout": "10 seconds"},
+	})
+	assert.NoError(err)
+	assert.Equal(2, opts.MaxRetries)
+	assert.Equal(10*time.Second, opts.Timeout)
+
+	// The default is in the syntax of the option
+	opts, err = FromContext(extpoints.Context{
+		Config: map[string]interface{}{"retryTimeout": ConfigOptions()["retryTimeout"].Default},
+	})
+	assert.NoError(err)
+	assert.Equal(DefaultOptions.Timeout, opts.Timeout)
+
+	_, err = FromContext(extpoints.Context{
+		Arguments: map[string]interface{}{"--retry-timeout": "soon"},
+	})
+	assert.Error(err)
+}
@@ -0,0 +1,128 @@
+package scopes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/taskcluster/taskcluster-cli/extpoints"
+)
+
+func init() {
+	extpoints.Register("scopes", command{})
+}
+
+type command struct{}
+
+func (command) ConfigOptions() map[string]extpoints.ConfigOption {
+	return nil
+}
+
+func (command) Summary() string {
+	return "Check and combine lists of scopes."
+}
+
+func (command) Usage() string {
+	return `Check and combine lists of scopes.
+
+Usage:
+  taskcluster scopes satisfies [--json] <scopes> <required>
+  taskcluster scopes normalize [--json] <scopes>
+  taskcluster scopes intersect [--json] <scopes> <other>
+  taskcluster scopes subtract [--json] <scopes> <other>
+
+Options:
+  --json  Print lists of scopes as a JSON array, instead of one per line
+
+Lists of scopes are given as a JSON array, as '@file' to read a JSON array or
+one scope per line from file, or as a single scope.
+
+  satisfies  Check if <scopes> satisfy all scopes in <required>, prints the
+             scopes that are missing and exits non-zero if any are.
+  normalize  Remove duplicate scopes and scopes satisfied by other scopes.
+  intersect  Print the scopes satisfied by both <scopes> and <other>.
+  subtract   Print the scopes in <scopes> that are not satisfied by <other>.
+`
+}
+
+func (command) Execute(context extpoints.Context) bool {
+	args := context.Arguments
+
+	lists := make(map[string][]string)
+	for _, name := range []string{"<scopes>", "<required>", "<other>"} {
+		value, ok := args[name].(string)
+		if !ok {
+			continue
+		}
+		list, err := parseList(value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: invalid list of scopes '%s': %v\n", value, err)
+			return false
+		}
+		lists[name] = list
+	}
+
+	var result []string
+	switch {
+	case args["satisfies"] == true:
+		result = Missing(lists["<scopes>"], lists["<required>"])
+		if len(result) == 0 {
+			fmt.Println("Satisfied")
+			return true
+		}
+		fmt.Fprintln(os.Stderr, "Missing scopes:")
+		printList(result, args["--json"] == true)
+		return false
+	case args["normalize"] == true:
+		result = Normalize(lists["<scopes>"])
+	case args["intersect"] == true:
+		result = Intersect(lists["<scopes>"], lists["<other>"])
+	case args["subtract"] == true:
+		result = Subtract(lists["<scopes>"], lists["<other>"])
+	}
+	printList(result, args["--json"] == true)
+	return true
+}
+
+// parseList parses a list of scopes given as a JSON array, '@file' or a
+// single scope.
+func parseList(value string) ([]string, error) {
+	if strings.HasPrefix(value, "@") {
+		data, err := ioutil.ReadFile(value[1:])
+		if err != nil {
+			return nil, err
+		}
+		var list []string
+		if json.Unmarshal(data, &list) == nil {
+			return list, nil
+		}
+		list = []string{}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				list = append(list, line)
+			}
+		}
+		return list, nil
+	}
+	if strings.HasPrefix(strings.TrimSpace(value), "[") {
+		var list []string
+		if err := json.Unmarshal([]byte(value), &list); err != nil {
+			return nil, err
+		}
+		return list, nil
+	}
+	return []string{value}, nil
+}
+
+func printList(scopes []string, asJSON bool) {
+	if asJSON {
+		data, _ := json.Marshal(scopes)
+		fmt.Println(string(data))
+		return
+	}
+	for _, scope := range scopes {
+		fmt.Println(scope)
+	}
+}
@@ -0,0 +1,132 @@
+// Package scopes implements the taskcluster scope algebra, where a scope
+// ending in '*' satisfies every scope it is a prefix of.
+//
+// Required scopes are given as scope expressions on the form [][]string,
+// where the expression is satisfied if all scopes of at least one of the
+// scope sets are satisfied. Required scopes may hold parameters like
+// '<taskId>', which are substituted before checking.
+package scopes
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var parameterPattern = regexp.MustCompile(`<([a-zA-Z0-9_-]+)>`)
+
+// Satisfies returns true, if scope is satisfied by one of the scopes in have.
+func Satisfies(have []string, scope string) bool {
+	for _, s := range have {
+		if s == scope || (strings.HasSuffix(s, "*") && strings.HasPrefix(scope, s[:len(s)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// Missing returns the scopes in required that aren't satisfied by have.
+func Missing(have, required []string) []string {
+	missing := []string{}
+	for _, scope := range required {
+		if !Satisfies(have, scope) {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+// SatisfiesExpression returns true, if have satisfies all scopes in at least
+// one of the scope sets in expression. An empty expression requires nothing.
+func SatisfiesExpression(have []string, expression [][]string) bool {
+	if len(expression) == 0 {
+		return true
+	}
+	for _, set := range expression {
+		if len(Missing(have, set)) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Substitute replaces parameters like '<taskId>' in scope with the values
+// from params. Parameters without a value are left as they are.
+func Substitute(scope string, params map[string]string) string {
+	return parameterPattern.ReplaceAllStringFunc(scope, func(match string) string {
+		if value, ok := params[match[1:len(match)-1]]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// HasParameters returns true, if scope has parameters left to substitute.
+func HasParameters(scope string) bool {
+	return parameterPattern.MatchString(scope)
+}
+
+// SubstituteExpression substitutes parameters in all scopes of expression.
+func SubstituteExpression(expression [][]string, params map[string]string) [][]string {
+	result := make([][]string, len(expression))
+	for i, set := range expression {
+		result[i] = make([]string, len(set))
+		for j, scope := range set {
+			result[i][j] = Substitute(scope, params)
+		}
+	}
+	return result
+}
+
+// Normalize returns scopes sorted, without duplicates and without scopes that
+// are satisfied by other scopes in the list.
+func Normalize(scopes []string) []string {
+	unique := make(map[string]bool)
+	for _, scope := range scopes {
+		unique[scope] = true
+	}
+
+	result := []string{}
+	for scope := range unique {
+		redundant := false
+		for other := range unique {
+			if other != scope && Satisfies([]string{other}, scope) {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			result = append(result, scope)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Intersect returns the normalized list of scopes satisfied by both a and b.
+func Intersect(a, b []string) []string {
+	result := []string{}
+	for _, scope := range a {
+		if Satisfies(b, scope) {
+			result = append(result, scope)
+		}
+	}
+	for _, scope := range b {
+		if Satisfies(a, scope) {
+			result = append(result, scope)
+		}
+	}
+	return Normalize(result)
+}
+
+// Subtract returns the normalized list of scopes in a that are not satisfied
+// by b. Star scopes in a that are only partially satisfied by b are kept.
+func Subtract(a, b []string) []string {
+	result := []string{}
+	for _, scope := range a {
+		if !Satisfies(b, scope) {
+			result = append(result, scope)
+		}
+	}
+	return Normalize(result)
+}
@@ -0,0 +1,73 @@
+package scopes
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestSatisfies(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(Satisfies([]string{"queue:create-task:*"}, "queue:create-task:aws/test"))
+	assert.True(Satisfies([]string{"a", "b"}, "b"))
+	assert.True(Satisfies([]string{"*"}, "anything"))
+	assert.True(Satisfies([]string{"queue:*"}, "queue:"))
+	assert.False(Satisfies([]string{"queue:create-task"}, "queue:create-task:aws"))
+	assert.False(Satisfies([]string{"queue:create-task:aws*"}, "queue:create-task:gce"))
+	assert.False(Satisfies(nil, "a"))
+}
+
+func TestMissing(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"b:x"}, Missing([]string{"a:*"}, []string{"a:x", "b:x"}))
+	assert.Equal([]string{}, Missing([]string{"*"}, []string{"a:x", "b:x"}))
+}
+
+func TestSatisfiesExpression(t *testing.T) {
+	assert := assert.New(t)
+
+	expr := [][]string{{"a", "b"}, {"c"}}
+	assert.True(SatisfiesExpression([]string{"a", "b"}, expr))
+	assert.True(SatisfiesExpression([]string{"c"}, expr))
+	assert.False(SatisfiesExpression([]string{"a"}, expr))
+	assert.True(SatisfiesExpression(nil, nil))
+}
+
+func TestSubstitute(t *testing.T) {
+	assert := assert.New(t)
+
+	params := map[string]string{"clientId": "tester", "bucket": "b"}
+	assert.Equal("auth:create-client:tester", Substitute("auth:create-client:<clientId>", params))
+	assert.Equal("auth:aws-s3:<level>:b/<prefix>", Substitute("auth:aws-s3:<level>:<bucket>/<prefix>", params))
+	assert.True(HasParameters("auth:aws-s3:<level>:b/<prefix>"))
+	assert.False(HasParameters("auth:create-client:tester"))
+	assert.Equal(
+		[][]string{{"a:tester"}, {"b:<x>"}},
+		SubstituteExpression([][]string{{"a:<clientId>"}, {"b:<x>"}}, params),
+	)
+}
+
+func TestNormalize(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"a*", "b"}, Normalize([]string{"b", "abc", "a*", "ab*", "b"}))
+	assert.Equal([]string{"*"}, Normalize([]string{"x", "*", "y*"}))
+	assert.Equal([]string{}, Normalize(nil))
+}
+
+func TestIntersect(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"foo:bar*"}, Intersect([]string{"foo:*"}, []string{"foo:bar*", "baz"}))
+	assert.Equal([]string{"a", "b", "c"}, Intersect([]string{"a", "b", "c"}, []string{"*"}))
+	assert.Equal([]string{}, Intersect([]string{"a"}, []string{"b"}))
+}
+
+func TestSubtract(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"b:x"}, Subtract([]string{"a:x", "a:y", "b:x"}, []string{"a:*"}))
+	assert.Equal([]string{"a:*"}, Subtract([]string{"a:*"}, []string{"a:x"}))
+}
@@ -0,0 +1,75 @@
+package signurl
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/taskcluster/taskcluster-cli/extpoints"
+	fromNow "github.com/taskcluster/taskcluster-cli/from-now"
+)
+
+func init() {
+	extpoints.Register("signurl", signurl{})
+}
+
+type signurl struct{}
+
+func (signurl) ConfigOptions() map[string]extpoints.ConfigOption {
+	return nil
+}
+
+func (signurl) Summary() string {
+	return "Create a signed URL that is valid for a limited time."
+}
+
+func (signurl) UsesCredentials() bool {
+	return true
+}
+
+func (signurl) Usage() string {
+	return `Create a (bewit) signed URL for a GET request with the configured credentials.
+The signed URL can be shared to give access to a single resource, e.g. a
+private artifact, until it expires, without sharing the credentials.
+
+Usage:
+  taskcluster signurl [--expires <duration>] <url>
+
+Options:
+  --expires <duration>  Time until the signed URL expires [default: 1 hour]
+`
+}
+
+func (signurl) Execute(context extpoints.Context) bool {
+	argv := context.Arguments
+	if context.Credentials == nil {
+		fmt.Fprintln(os.Stderr, "No credentials configured to sign URLs with")
+		return false
+	}
+
+	u := argv["<url>"].(string)
+	if parsed, err := url.Parse(u); err != nil || !parsed.IsAbs() {
+		fmt.Fprintf(os.Stderr, "Invalid URL '%s', must be an absolute URL\n", u)
+		return false
+	}
+	expires := argv["--expires"].(string)
+	now := time.Now()
+	expiry, err := fromNow.Add(now, expires)
+	if err != nil || !expiry.After(now) {
+		fmt.Fprintf(os.Stderr, "Invalid duration '%s' given for --expires\n", expires)
+		return false
+	}
+
+	signed, err := context.Credentials.SignURL(u, expiry.Sub(now))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to sign URL, error: %s\n", err)
+		return false
+	}
+	if cert, err := context.Credentials.ParseCertificate(); err == nil && cert != nil && cert.ExpiryTime().Before(expiry) {
+		fmt.Fprintf(os.Stderr, "Warning: the signed URL stops working when the temporary credentials expire at %s\n",
+			cert.ExpiryTime().Format(time.RFC3339))
+	}
+	fmt.Println(signed)
+	return true
+}
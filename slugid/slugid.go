@@ -90,7 +90,13 @@ func v4() (string, error) {
 
 // generates uuid with "nice" properties
 func nice() (string, error) {
-	return sluglib.Nice(), nil
+	return Nice(), nil
+}
+
+// Nice returns a new slug with "nice" properties, which never starts with a
+// '-', as used for taskIds.
+func Nice() string {
+	return sluglib.Nice()
 }
 
 // decodes slug into a uuid
@@ -6,6 +6,9 @@ package main
 import _ "github.com/taskcluster/taskcluster-cli/apis"
 import _ "github.com/taskcluster/taskcluster-cli/config"
 import _ "github.com/taskcluster/taskcluster-cli/from-now"
+import _ "github.com/taskcluster/taskcluster-cli/scopes"
+import _ "github.com/taskcluster/taskcluster-cli/signurl"
+import _ "github.com/taskcluster/taskcluster-cli/slugid"
 import _ "github.com/taskcluster/taskcluster-cli/task"
 import _ "github.com/taskcluster/taskcluster-cli/version"
-import _ "github.com/taskcluster/taskcluster-cli/slugid"
+import _ "github.com/taskcluster/taskcluster-cli/whoami"
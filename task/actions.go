@@ -2,12 +2,18 @@ package task
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/taskcluster/taskcluster-cli/apis"
+	"github.com/taskcluster/taskcluster-cli/format"
+	fromNow "github.com/taskcluster/taskcluster-cli/from-now"
+	"github.com/taskcluster/taskcluster-cli/query"
 	tcclient "github.com/taskcluster/taskcluster-client-go"
-	"github.com/taskcluster/taskcluster-client-go/queue"
 )
 
 type arguments map[string]interface{}
@@ -25,7 +31,7 @@ func extractRunID(max int, param interface{}) (runID int, err error) {
 	if str, ok := param.(string); ok {
 		var id int
 		if id, err = strconv.Atoi(str); err == nil {
-			if id >= 0 && id < max {
+			if id >= 0 && id <= max {
 				runID = id
 			} else {
 				err = fmt.Errorf("given runID is out of range: %v", id)
@@ -38,6 +44,34 @@ func extractRunID(max int, param interface{}) (runID int, err error) {
 	return
 }
 
+// printQuery prints the values selected by the query expression expr from the
+// JSON representation of value.
+func printQuery(expr string, value interface{}) bool {
+	e, err := query.Parse(expr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: invalid query: %v\n", err)
+		return false
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(fmt.Sprintf("internal error: could not marshal %T: %v", value, err))
+	}
+	doc, err := format.Parse(data)
+	if err != nil {
+		panic(fmt.Sprintf("internal error: could not parse %T: %v", value, err))
+	}
+
+	results, err := e.Evaluate(doc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not evaluate query: %v\n", err)
+		return false
+	}
+	os.Stdout.Write(query.Render(results))
+
+	return true
+}
+
 func getRunStatusString(state, resolved string) string {
 	if resolved != "" {
 		return fmt.Sprintf("%s '%s'", state, resolved)
@@ -46,8 +80,8 @@ func getRunStatusString(state, resolved string) string {
 	return state
 }
 
-func (task) runStatus(credentials *tcclient.Credentials, args arguments) bool {
-	q := queue.New(credentials)
+func (t task) runStatus(credentials *tcclient.Credentials, args arguments) bool {
+	q := t.queue(credentials)
 	taskID := args["<taskId>"].(string)
 
 	s, err := q.Status(taskID)
@@ -56,6 +90,10 @@ func (task) runStatus(credentials *tcclient.Credentials, args arguments) bool {
 		return false
 	}
 
+	if expr, ok := args["--query"].(string); ok {
+		return printQuery(expr, s.Status)
+	}
+
 	if args["--all-runs"].(bool) {
 		for _, r := range s.Status.Runs {
 			fmt.Printf("Run #%d: %s\n", r.RunID, getRunStatusString(r.State, r.ReasonResolved))
@@ -74,38 +112,38 @@ func (task) runStatus(credentials *tcclient.Credentials, args arguments) bool {
 	return true
 }
 
-func (task) runName(credentials *tcclient.Credentials, args arguments) bool {
-	q := queue.New(credentials)
+func (t task) runName(credentials *tcclient.Credentials, args arguments) bool {
+	q := t.queue(credentials)
 	taskID := args["<taskId>"].(string)
 
-	t, err := q.Task(taskID)
+	def, err := q.Task(taskID)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: could not get the task %s: %v\n", taskID, err)
 		return false
 	}
 
-	fmt.Println(t.Metadata.Name)
+	fmt.Println(def.Metadata.Name)
 
 	return true
 }
 
-func (task) runGroup(credentials *tcclient.Credentials, args arguments) bool {
-	q := queue.New(credentials)
+func (t task) runGroup(credentials *tcclient.Credentials, args arguments) bool {
+	q := t.queue(credentials)
 	taskID := args["<taskId>"].(string)
 
-	t, err := q.Task(taskID)
+	def, err := q.Task(taskID)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: could not get the task %s: %v\n", taskID, err)
 		return false
 	}
 
-	fmt.Println(t.TaskGroupID)
+	fmt.Println(def.TaskGroupID)
 
 	return true
 }
 
-func (task) runArtifacts(credentials *tcclient.Credentials, args arguments) bool {
-	q := queue.New(credentials)
+func (t task) runArtifacts(credentials *tcclient.Credentials, args arguments) bool {
+	q := t.queue(credentials)
 	taskID := args["<taskId>"].(string)
 
 	s, err := q.Status(taskID)
@@ -144,29 +182,62 @@ func (task) runArtifacts(credentials *tcclient.Credentials, args arguments) bool
 	return true
 }
 
-func (task) runCancel(credentials *tcclient.Credentials, args arguments) bool {
-	q := queue.New(credentials)
+func (t task) runArtifactURL(credentials *tcclient.Credentials, args arguments) bool {
 	taskID := args["<taskId>"].(string)
+	name := args["<name>"].(string)
 
-	c, err := q.CancelTask(taskID)
+	params := map[string]string{"taskId": taskID, "name": name}
+	method := "getLatestArtifact"
+	if run, ok := args["--run"].(string); ok {
+		if _, err := strconv.Atoi(run); err != nil {
+			fmt.Fprintf(os.Stderr, "error: invalid runID: %v\n", run)
+			return false
+		}
+		params["runId"] = run
+		method = "getArtifact"
+	}
+	u, err := apis.EntryURL("queue", method, t.rootURL, params, nil)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: could not cancel the task %s: %v\n", taskID, err)
+		panic(fmt.Sprintf("internal error: could not construct artifact URL: %v", err))
+	}
+
+	// Public artifacts don't need a signed URL
+	if strings.HasPrefix(name, "public/") {
+		fmt.Println(u)
+		return true
+	}
+	if credentials == nil {
+		fmt.Fprintf(os.Stderr, "error: credentials are required to sign the URL of private artifact %s\n", name)
 		return false
 	}
 
-	run := c.Status.Runs[len(c.Status.Runs)-1]
-	fmt.Println(getRunStatusString(run.State, run.ReasonResolved))
+	expires, ok := args["--expires"].(string)
+	if !ok {
+		expires = "1 hour"
+	}
+	now := time.Now()
+	expiry, err := fromNow.Add(now, expires)
+	if err != nil || !expiry.After(now) {
+		fmt.Fprintf(os.Stderr, "error: invalid duration given for --expires: %s\n", expires)
+		return false
+	}
+	signed, err := clientCredentials(credentials).SignURL(u, expiry.Sub(now))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not sign the artifact URL: %v\n", err)
+		return false
+	}
+	fmt.Println(signed)
 
 	return true
 }
 
-func (task) runRerun(credentials *tcclient.Credentials, args arguments) bool {
-	q := queue.New(credentials)
+func (t task) runCancel(credentials *tcclient.Credentials, args arguments) bool {
+	q := t.queue(credentials)
 	taskID := args["<taskId>"].(string)
 
-	c, err := q.RerunTask(taskID)
+	c, err := q.CancelTask(taskID)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: could not rerun the task %s: %v\n", taskID, err)
+		fmt.Fprintf(os.Stderr, "error: could not cancel the task %s: %v\n", taskID, err)
 		return false
 	}
 
@@ -176,37 +247,24 @@ func (task) runRerun(credentials *tcclient.Credentials, args arguments) bool {
 	return true
 }
 
-func (task) runComplete(credentials *tcclient.Credentials, args arguments) bool {
-	q := queue.New(credentials)
+func (t task) runRerun(credentials *tcclient.Credentials, args arguments) bool {
+	q := t.queue(credentials)
 	taskID := args["<taskId>"].(string)
 
-	s, err := q.Status(taskID)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: could not get the status of the task %s: %v\n", taskID, err)
-		return false
-	}
-
-	c, err := q.ClaimTask(taskID, fmt.Sprint(len(s.Status.Runs)-1), &queue.TaskClaimRequest{
-		WorkerGroup: s.Status.WorkerType,
-		WorkerID:    "taskcluster-cli",
-	})
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: could not claim the task %s: %v\n", taskID, err)
-		return false
-	}
-
-	wq := queue.New(&tcclient.Credentials{
-		ClientID:    c.Credentials.ClientID,
-		AccessToken: c.Credentials.AccessToken,
-		Certificate: c.Credentials.Certificate,
-	})
-	r, err := wq.ReportCompleted(taskID, fmt.Sprint(c.RunID))
+	c, err := q.RerunTask(taskID)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: could not complete the task %s: %v\n", taskID, err)
+		fmt.Fprintf(os.Stderr, "error: could not rerun the task %s: %v\n", taskID, err)
 		return false
 	}
 
-	fmt.Println(getRunStatusString(r.Status.Runs[c.RunID].State, r.Status.Runs[c.RunID].ReasonResolved))
+	run := c.Status.Runs[len(c.Status.Runs)-1]
+	fmt.Println(getRunStatusString(run.State, run.ReasonResolved))
 
 	return true
 }
+
+func (t task) runComplete(credentials *tcclient.Credentials, args arguments) bool {
+	taskID := args["<taskId>"].(string)
+	return t.resolve(credentials, taskID, "completed", "",
+		args["--worker-group"].(string), args["--worker-id"].(string))
+}
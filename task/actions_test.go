@@ -0,0 +1,29 @@
+package task
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestExtractRunID(t *testing.T) {
+	assert := assert.New(t)
+
+	runID, err := extractRunID(2, nil)
+	assert.NoError(err)
+	assert.Equal(2, runID)
+
+	runID, err = extractRunID(2, "0")
+	assert.NoError(err)
+	assert.Equal(0, runID)
+
+	// The latest run can be given explicitly
+	runID, err = extractRunID(2, "2")
+	assert.NoError(err)
+	assert.Equal(2, runID)
+
+	_, err = extractRunID(2, "3")
+	assert.Error(err)
+	_, err = extractRunID(2, "-1")
+	assert.Error(err)
+}
@@ -0,0 +1,218 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/taskcluster/taskcluster-cli/apis"
+	"github.com/taskcluster/taskcluster-cli/client"
+	fromNow "github.com/taskcluster/taskcluster-cli/from-now"
+	"github.com/taskcluster/taskcluster-cli/slugid"
+	tcclient "github.com/taskcluster/taskcluster-client-go"
+	"github.com/taskcluster/taskcluster-client-go/queue"
+)
+
+// paramPattern matches parameter references in strings, e.g. '${branch}'.
+var paramPattern = regexp.MustCompile(`\$\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}`)
+
+func (t task) runCreate(credentials *tcclient.Credentials, args arguments) bool {
+	q := t.queue(credentials)
+	file := args["<file>"].(string)
+
+	params, err := parseParams(args["--param"].([]string))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return false
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not read %s: %v\n", file, err)
+		return false
+	}
+	template, err := apis.ParseYAML(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not parse %s: %v\n", file, err)
+		return false
+	}
+
+	taskID, def, err := renderTask(template, params, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not render %s: %v\n", file, err)
+		return false
+	}
+	if err := submitTask(q, taskID, def); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return false
+	}
+
+	fmt.Println(taskID)
+	fmt.Println(client.InspectorURL(t.rootURL, taskID))
+	return true
+}
+
+// submitTask validates def against the input schema of createTask, and creates
+// the task with taskID.
+func submitTask(q *queue.Queue, taskID string, def map[string]interface{}) error {
+	data, err := json.Marshal(def)
+	if err != nil {
+		panic(fmt.Sprintf("internal error: could not marshal task definition: %v", err))
+	}
+
+	problems, err := apis.ValidateInput("queue", "createTask", data)
+	if err != nil {
+		return fmt.Errorf("could not validate the task definition: %v", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid task definition:\n - %s", strings.Join(problems, "\n - "))
+	}
+
+	var request queue.TaskDefinitionRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return fmt.Errorf("invalid task definition: %v", err)
+	}
+	// The client can't omit expires, so default it as the queue would
+	if time.Time(request.Expires).IsZero() {
+		request.Expires = tcclient.Time(time.Time(request.Deadline).AddDate(1, 0, 0))
+	}
+	if _, err := q.CreateTask(taskID, &request); err != nil {
+		return fmt.Errorf("could not create task %s: %v", taskID, err)
+	}
+	return nil
+}
+
+// parseParams parses parameters given as 'key=value'.
+func parseParams(assignments []string) (map[string]string, error) {
+	params := make(map[string]string, len(assignments))
+	for _, assignment := range assignments {
+		i := strings.IndexByte(assignment, '=')
+		if i < 1 {
+			return nil, fmt.Errorf("invalid parameter '%s', expected key=value", assignment)
+		}
+		params[assignment[:i]] = assignment[i+1:]
+	}
+	return params, nil
+}
+
+// renderTask renders a task template with params, and returns the taskId and
+// the task definition. The taskId is the 'taskId' parameter, or a new slug if
+// not given, and is available to the template as a parameter. The 'created'
+// time defaults to now.
+func renderTask(template interface{}, params map[string]string, now time.Time) (string, map[string]interface{}, error) {
+	if _, ok := params["taskId"]; !ok {
+		withID := map[string]string{"taskId": slugid.Nice()}
+		for k, v := range params {
+			withID[k] = v
+		}
+		params = withID
+	}
+
+	rendered, err := render(template, params, now)
+	if err != nil {
+		return "", nil, err
+	}
+	def, ok := rendered.(map[string]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("the template must be an object")
+	}
+
+	if _, ok := def["created"]; !ok {
+		def["created"] = tcclient.Time(now)
+	}
+	return params["taskId"], def, nil
+}
+
+// render returns value with parameters referenced as '${name}' in strings
+// substituted, objects on the form {$eval: name} replaced by the value of the
+// parameter parsed as YAML, and objects on the form {$fromNow: '2 hours'}
+// replaced by the time relative to now.
+func render(value interface{}, params map[string]string, now time.Time) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		var err error
+		s := paramPattern.ReplaceAllStringFunc(v, func(ref string) string {
+			name := paramPattern.FindStringSubmatch(ref)[1]
+			param, ok := params[name]
+			if !ok && err == nil {
+				err = fmt.Errorf("undefined parameter '%s'", name)
+			}
+			return param
+		})
+		return s, err
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, val := range v {
+			var err error
+			if result[i], err = render(val, params, now); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case map[string]interface{}:
+		if operator, ok := renderOperator(v); ok {
+			arg, err := render(v[operator], params, now)
+			if err != nil {
+				return nil, err
+			}
+			s, ok := arg.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s expects a string, got %v", operator, arg)
+			}
+			switch operator {
+			case "$eval":
+				param, ok := params[s]
+				if !ok {
+					return nil, fmt.Errorf("undefined parameter '%s'", s)
+				}
+				parsed, err := apis.ParseYAML([]byte(param))
+				if err != nil || parsed == nil {
+					return param, nil
+				}
+				return parsed, nil
+			case "$fromNow":
+				t, err := fromNow.Add(now, s)
+				if err != nil {
+					return nil, fmt.Errorf("invalid $fromNow '%s': %v", s, err)
+				}
+				return tcclient.Time(t), nil
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		result := make(map[string]interface{}, len(v))
+		for _, key := range keys {
+			if strings.HasPrefix(key, "$") {
+				return nil, fmt.Errorf("unsupported operator '%s'", key)
+			}
+			var err error
+			if result[key], err = render(v[key], params, now); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
+
+// renderOperator returns the operator, if v is an object with a single key
+// that is a supported operator.
+func renderOperator(v map[string]interface{}) (string, bool) {
+	if len(v) != 1 {
+		return "", false
+	}
+	for key := range v {
+		if key == "$eval" || key == "$fromNow" {
+			return key, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,117 @@
+package task
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/taskcluster/taskcluster-cli/slugid"
+	tcclient "github.com/taskcluster/taskcluster-client-go"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	params := map[string]string{"branch": "main", "retries": "3", "version": "1.10"}
+
+	value, err := render(map[string]interface{}{
+		"name":     "build ${branch} ${ version }",
+		"retries":  map[string]interface{}{"$eval": "retries"},
+		"version":  map[string]interface{}{"$eval": "version"},
+		"deadline": map[string]interface{}{"$fromNow": "2 hours"},
+		"list":     []interface{}{"${branch}", 1, true},
+	}, params, now)
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{
+		"name":     "build main 1.10",
+		"retries":  3,
+		"version":  1.1,
+		"deadline": tcclient.Time(now.Add(2 * time.Hour)),
+		"list":     []interface{}{"main", 1, true},
+	}, value)
+
+	_, err = render("${missing}", params, now)
+	assert.Error(err)
+	_, err = render(map[string]interface{}{"$eval": "missing"}, params, now)
+	assert.Error(err)
+	_, err = render(map[string]interface{}{"$fromNow": "soon"}, params, now)
+	assert.Error(err)
+	_, err = render(map[string]interface{}{"$if": "branch"}, params, now)
+	assert.Error(err)
+}
+
+func TestRenderTask(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	template := map[string]interface{}{"taskGroupId": "${taskId}"}
+
+	taskID, def, err := renderTask(template, map[string]string{}, now)
+	assert.NoError(err)
+	assert.True(slugid.NICE_SLUG_REGEXP.MatchString(taskID))
+	assert.Equal(map[string]interface{}{"taskGroupId": taskID, "created": tcclient.Time(now)}, def)
+
+	taskID, def, err = renderTask(template, map[string]string{"taskId": "abc"}, now)
+	assert.NoError(err)
+	assert.Equal("abc", taskID)
+	assert.Equal("abc", def["taskGroupId"])
+
+	_, _, err = renderTask([]interface{}{}, nil, now)
+	assert.Error(err)
+}
+
+func TestCreate(t *testing.T) {
+	assert := assert.New(t)
+
+	var created map[string]interface{}
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.Method + " " + r.URL.Path
+		json.NewDecoder(r.Body).Decode(&created)
+		w.Write([]byte(`{"status": {}}`))
+	}))
+	defer server.Close()
+	tk := task{queueBaseURL: server.URL}
+
+	dir, err := ioutil.TempDir("", "taskcluster-create")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "task.yml")
+	assert.NoError(ioutil.WriteFile(file, []byte(`
+provisionerId: aws-provisioner-v1
+workerType: ${workerType}
+deadline: {$fromNow: '1 day'}
+payload:
+  command: [echo, hello]
+metadata:
+  name: hello
+  description: Say hello
+  owner: nobody@example.com
+  source: https://example.com/task.yml
+`), 0644))
+
+	assert.True(tk.runCreate(nil, arguments{
+		"<file>":  file,
+		"--param": []string{"workerType=tutorial", "taskId=fN1SbArXTPSVFNUvaOlinQ"},
+	}))
+	assert.Equal("PUT /task/fN1SbArXTPSVFNUvaOlinQ", path)
+	assert.Equal("tutorial", created["workerType"])
+	deadline, err := time.Parse(time.RFC3339, created["deadline"].(string))
+	assert.NoError(err)
+	expires, err := time.Parse(time.RFC3339, created["expires"].(string))
+	assert.NoError(err)
+	assert.Equal(deadline.AddDate(1, 0, 0), expires)
+	assert.Equal([]interface{}{"echo", "hello"}, created["payload"].(map[string]interface{})["command"])
+
+	// Missing parameters and invalid definitions aren't created
+	path = ""
+	assert.False(tk.runCreate(nil, arguments{"<file>": file, "--param": []string{}}))
+	assert.False(tk.runCreate(nil, arguments{"<file>": file, "--param": []string{"workerType=bad/type"}}))
+	assert.Equal("", path)
+}
@@ -0,0 +1,477 @@
+package task
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/taskcluster/taskcluster-cli/apis"
+	"github.com/taskcluster/taskcluster-cli/client"
+	"github.com/taskcluster/taskcluster-cli/retry"
+	tcclient "github.com/taskcluster/taskcluster-client-go"
+)
+
+// progressInterval is the interval between progress reports while
+// downloading.
+const progressInterval = 5 * time.Second
+
+func (t task) runDownload(credentials *tcclient.Credentials, args arguments) bool {
+	q := t.queue(credentials)
+	taskID := args["<taskId>"].(string)
+
+	s, err := q.Status(taskID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not get the task %s: %v\n", taskID, err)
+		return false
+	}
+	if len(s.Status.Runs) == 0 {
+		fmt.Fprintf(os.Stderr, "error: task %s has no runs\n", taskID)
+		return false
+	}
+	runID, err := extractRunID(len(s.Status.Runs)-1, args["--run"])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: invalid runID: %v\n", err)
+		return false
+	}
+
+	jobs, err := strconv.Atoi(args["--jobs"].(string))
+	if err != nil || jobs < 1 {
+		fmt.Fprintf(os.Stderr, "error: invalid number of jobs: %v\n", args["--jobs"])
+		return false
+	}
+	dest := args["--dest"].(string)
+	glob, _ := args["--glob"].(string)
+	names, _ := args["<artifactName>"].([]string)
+
+	// List the artifacts of the run
+	var all []string
+	continuation := ""
+	for {
+		a, err := q.ListArtifacts(taskID, fmt.Sprint(runID), continuation, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: could not fetch artifacts for task %s run %v: %v\n", taskID, runID, err)
+			return false
+		}
+		for _, ar := range a.Artifacts {
+			all = append(all, ar.Name)
+		}
+		continuation = a.ContinuationToken
+		if continuation == "" {
+			break
+		}
+	}
+	selected, err := selectArtifacts(all, names, glob)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return false
+	}
+	if len(selected) == 0 {
+		fmt.Fprintf(os.Stderr, "error: no artifacts to download for task %s run %v\n", taskID, runID)
+		return false
+	}
+
+	var downloads []download
+	for _, name := range selected {
+		u, err := apis.EntryURL("queue", "getArtifact", t.rootURL, map[string]string{
+			"taskId": taskID,
+			"runId":  fmt.Sprint(runID),
+			"name":   name,
+		}, nil)
+		if err != nil {
+			panic(fmt.Sprintf("internal error: could not construct artifact URL: %v", err))
+		}
+		p, err := artifactPath(dest, name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return false
+		}
+		downloads = append(downloads, download{name: name, url: u, path: p})
+	}
+
+	return newDownloader(clientCredentials(credentials)).downloadAll(downloads, jobs)
+}
+
+// selectArtifacts returns the artifacts from all that are given in names, or
+// all artifacts if names is empty, which match the glob pattern, if any.
+func selectArtifacts(all, names []string, glob string) ([]string, error) {
+	selected := all
+	if len(names) > 0 {
+		exists := make(map[string]bool, len(all))
+		for _, name := range all {
+			exists[name] = true
+		}
+		for _, name := range names {
+			if !exists[name] {
+				return nil, fmt.Errorf("no artifact named %s", name)
+			}
+		}
+		selected = names
+	}
+	if glob == "" {
+		return selected, nil
+	}
+
+	var matches []string
+	for _, name := range selected {
+		match, err := path.Match(glob, name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %s: %v", glob, err)
+		}
+		if match {
+			matches = append(matches, name)
+		}
+	}
+	return matches, nil
+}
+
+// artifactPath returns the path in dest to download the artifact name to,
+// refusing names that would end up outside dest.
+func artifactPath(dest, name string) (string, error) {
+	p := filepath.Join(dest, filepath.FromSlash(name))
+	rel, err := filepath.Rel(dest, p)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("refusing to download artifact %s outside of %s", name, dest)
+	}
+	return p, nil
+}
+
+// clientCredentials converts credentials from tcclient, returning nil if
+// credentials is nil.
+func clientCredentials(credentials *tcclient.Credentials) *client.Credentials {
+	if credentials == nil {
+		return nil
+	}
+	return &client.Credentials{
+		ClientID:         credentials.ClientID,
+		AccessToken:      credentials.AccessToken,
+		Certificate:      credentials.Certificate,
+		AuthorizedScopes: credentials.AuthorizedScopes,
+	}
+}
+
+// A download of the artifact name from url to path.
+type download struct {
+	name string
+	url  string
+	path string
+}
+
+// permanentError is an error that won't go away by retrying the download.
+type permanentError struct {
+	error
+}
+
+type downloader struct {
+	// bytes is the number of bytes received, updated atomically and first
+	// for 64-bit alignment
+	bytes   int64
+	client  *http.Client
+	options retry.Options
+	// sleep is used to wait between attempts, replaced in tests
+	sleep func(time.Duration)
+}
+
+// httpClient returns a client retrying requests with opts and signing them
+// with credentials, if not nil. Redirects, e.g. to S3, are followed without
+// signing them.
+func httpClient(credentials *client.Credentials, opts retry.Options) *http.Client {
+	var sign func(req *http.Request, body []byte) error
+	if credentials != nil {
+		sign = func(req *http.Request, body []byte) error {
+			return credentials.SignRequest(req, nil)
+		}
+	}
+	return retry.NewClient(opts, sign)
+}
+
+// newDownloader returns a downloader signing requests with credentials, if
+// not nil. The downloader retries by resuming downloads itself, so its
+// client makes a single attempt per request.
+func newDownloader(credentials *client.Credentials) *downloader {
+	return &downloader{
+		client:  httpClient(credentials, retry.Options{}),
+		options: retry.DefaultOptions,
+		sleep:   time.Sleep,
+	}
+}
+
+// downloadAll downloads using a pool of jobs workers, reporting progress on
+// stderr. It returns false if any download failed.
+func (d *downloader) downloadAll(downloads []download, jobs int) bool {
+	work := make(chan download)
+	var failed, done int32
+	var wg sync.WaitGroup
+	for i := 0; i < jobs && i < len(downloads); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dl := range work {
+				if err := d.download(dl); err != nil {
+					fmt.Fprintf(os.Stderr, "error: could not download artifact %s: %v\n", dl.name, err)
+					atomic.AddInt32(&failed, 1)
+				} else {
+					fmt.Fprintf(os.Stderr, "Downloaded %s\n", dl.path)
+				}
+				atomic.AddInt32(&done, 1)
+			}
+		}()
+	}
+
+	// Report progress until all workers are done
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fmt.Fprintf(os.Stderr, "Downloaded %d of %d artifacts, received %s\n",
+					atomic.LoadInt32(&done), len(downloads), humanSize(atomic.LoadInt64(&d.bytes)))
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	for _, dl := range downloads {
+		work <- dl
+	}
+	close(work)
+	wg.Wait()
+	close(stop)
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "error: failed to download %d of %d artifacts\n", failed, len(downloads))
+		return false
+	}
+	return true
+}
+
+// download downloads dl, resuming from where the previous attempt stopped.
+func (d *downloader) download(dl download) error {
+	for attempt := 0; ; attempt++ {
+		err := d.attempt(dl)
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(permanentError); ok || attempt >= d.options.MaxRetries {
+			return err
+		}
+		d.sleep(d.options.Delay(attempt + 1))
+	}
+}
+
+// attempt downloads dl to a partial file next to dl.path, using a range
+// request to resume if the partial file exists, and moves it in place when
+// the download is complete and verified.
+func (d *downloader) attempt(dl download) error {
+	partial := dl.path + ".partial"
+	if err := os.MkdirAll(filepath.Dir(dl.path), 0755); err != nil {
+		return permanentError{err}
+	}
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return permanentError{err}
+	}
+	defer f.Close()
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return permanentError{err}
+	}
+
+	req, err := http.NewRequest("GET", dl.url, nil)
+	if err != nil {
+		return permanentError{err}
+	}
+	// Ask for the content as it is stored, so ranges refer to stored bytes,
+	// content-encoding is handled when the download is complete
+	req.Header.Set("Accept-Encoding", "identity")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	res, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	total := int64(-1)
+	switch {
+	case res.StatusCode == http.StatusPartialContent:
+		var start int64
+		if _, err := fmt.Sscanf(res.Header.Get("Content-Range"), "bytes %d-", &start); err != nil || start != offset {
+			f.Truncate(0)
+			return fmt.Errorf("unexpected content range '%s'", res.Header.Get("Content-Range"))
+		}
+		if i := strings.LastIndex(res.Header.Get("Content-Range"), "/"); i != -1 {
+			if n, err := strconv.ParseInt(res.Header.Get("Content-Range")[i+1:], 10, 64); err == nil {
+				total = n
+			}
+		}
+	case res.StatusCode == http.StatusOK:
+		// The server ignored the range, start over
+		if err := f.Truncate(0); err != nil {
+			return permanentError{err}
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return permanentError{err}
+		}
+		total = res.ContentLength
+	case res.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		f.Truncate(0)
+		return fmt.Errorf("could not resume download, starting over")
+	default:
+		err := responseError(res)
+		if res.StatusCode/100 == 5 {
+			return err
+		}
+		return permanentError{err}
+	}
+
+	if _, err := io.Copy(f, &countingReader{r: res.Body, n: &d.bytes}); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return permanentError{err}
+	}
+	return finish(partial, dl.path, total, res.Header)
+}
+
+// finish verifies the complete download in partial against total size and
+// checksums from header, if available, and moves it to dst, decompressing it
+// if it has gzip content-encoding. The partial file is removed if it doesn't
+// verify.
+func finish(partial, dst string, total int64, header http.Header) error {
+	fail := func(format string, a ...interface{}) error {
+		os.Remove(partial)
+		return permanentError{fmt.Errorf(format, a...)}
+	}
+
+	info, err := os.Stat(partial)
+	if err != nil {
+		return permanentError{err}
+	}
+	if total >= 0 && info.Size() != total {
+		return fail("received %d bytes, expected %d", info.Size(), total)
+	}
+
+	// Verify the bytes as transferred
+	gzipped := strings.EqualFold(header.Get("Content-Encoding"), "gzip")
+	if sum := header.Get("X-Amz-Meta-Transfer-Sha256"); sum != "" {
+		if actual, err := fileSha256(partial); err != nil {
+			return permanentError{err}
+		} else if actual != sum {
+			return fail("sha256 mismatch, expected %s, got %s", sum, actual)
+		}
+	}
+	if !gzipped {
+		if sum := header.Get("X-Amz-Meta-Content-Sha256"); sum != "" {
+			if actual, err := fileSha256(partial); err != nil {
+				return permanentError{err}
+			} else if actual != sum {
+				return fail("sha256 mismatch, expected %s, got %s", sum, actual)
+			}
+		}
+		return os.Rename(partial, dst)
+	}
+
+	// Decompress, verifying the content
+	in, err := os.Open(partial)
+	if err != nil {
+		return permanentError{err}
+	}
+	defer in.Close()
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return fail("invalid gzip content: %v", err)
+	}
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return permanentError{err}
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), zr)
+	out.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return fail("invalid gzip content: %v", err)
+	}
+	if size := header.Get("X-Amz-Meta-Content-Length"); size != "" && size != strconv.FormatInt(n, 10) {
+		os.Remove(tmp)
+		return fail("content is %d bytes, expected %s", n, size)
+	}
+	if sum := header.Get("X-Amz-Meta-Content-Sha256"); sum != "" && sum != hex.EncodeToString(h.Sum(nil)) {
+		os.Remove(tmp)
+		return fail("sha256 mismatch, expected %s, got %s", sum, hex.EncodeToString(h.Sum(nil)))
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return permanentError{err}
+	}
+	return os.Remove(partial)
+}
+
+// responseError returns an error for an unsuccessful response, with the
+// message from the response body, if any.
+func responseError(res *http.Response) error {
+	data, _ := ioutil.ReadAll(io.LimitReader(res.Body, 64*1024))
+	var body struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Message != "" {
+		return fmt.Errorf("status %d: %s", res.StatusCode, body.Message)
+	}
+	return fmt.Errorf("status %d", res.StatusCode)
+}
+
+func fileSha256(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// countingReader adds the number of bytes read to n.
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(c.n, int64(n))
+	return n, err
+}
+
+// humanSize formats a number of bytes for humans.
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,176 @@
+package task
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestSelectArtifacts(t *testing.T) {
+	assert := assert.New(t)
+
+	all := []string{"public/logs/live.log", "public/logs/live_backing.log", "public/build/target.tar.gz", "private/secret"}
+
+	selected, err := selectArtifacts(all, nil, "")
+	assert.NoError(err)
+	assert.Equal(all, selected)
+
+	selected, err = selectArtifacts(all, nil, "public/logs/*")
+	assert.NoError(err)
+	assert.Equal([]string{"public/logs/live.log", "public/logs/live_backing.log"}, selected)
+
+	selected, err = selectArtifacts(all, []string{"private/secret", "public/logs/live.log"}, "public/*/*")
+	assert.NoError(err)
+	assert.Equal([]string{"public/logs/live.log"}, selected)
+
+	_, err = selectArtifacts(all, []string{"public/missing"}, "")
+	assert.Error(err)
+	_, err = selectArtifacts(all, nil, "[")
+	assert.Error(err)
+}
+
+func TestArtifactPath(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := artifactPath("out", "public/logs/live.log")
+	assert.NoError(err)
+	assert.Equal(filepath.Join("out", "public", "logs", "live.log"), p)
+
+	for _, name := range []string{"../escape", "public/../../escape", ".", "public/.."} {
+		_, err = artifactPath("out", name)
+		assert.Error(err, name)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	assert := assert.New(t)
+
+	content := []byte(strings.Repeat("taskcluster artifact content\n", 1000))
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(content)
+	zw.Close()
+
+	var mu sync.Mutex
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/plain":
+			w.Header().Set("X-Amz-Meta-Content-Sha256", sha256Hex(content))
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Header().Set("X-Amz-Meta-Content-Sha256", sha256Hex(content))
+			w.Header().Set("X-Amz-Meta-Transfer-Sha256", sha256Hex(gz.Bytes()))
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(gz.Bytes()))
+		case "/corrupt":
+			w.Header().Set("X-Amz-Meta-Content-Sha256", sha256Hex([]byte("other")))
+			w.Write(content)
+		case "/redirect":
+			http.Redirect(w, r, "/plain", http.StatusSeeOther)
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Artifact not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "taskcluster-download")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	d := newDownloader(nil)
+	d.sleep = func(time.Duration) {}
+
+	// Plain download, following redirects
+	dst := filepath.Join(dir, "public", "plain.txt")
+	assert.NoError(d.download(download{name: "plain", url: server.URL + "/redirect", path: dst}))
+	data, err := ioutil.ReadFile(dst)
+	assert.NoError(err)
+	assert.Equal(content, data)
+
+	// Resume a partial download
+	dst = filepath.Join(dir, "resumed.txt")
+	assert.NoError(ioutil.WriteFile(dst+".partial", content[:1000], 0644))
+	ranges = nil
+	assert.NoError(d.download(download{name: "plain", url: server.URL + "/plain", path: dst}))
+	assert.Equal([]string{"bytes=1000-"}, ranges)
+	data, err = ioutil.ReadFile(dst)
+	assert.NoError(err)
+	assert.Equal(content, data)
+	_, err = os.Stat(dst + ".partial")
+	assert.True(os.IsNotExist(err))
+
+	// Resume and decompress gzip content-encoding
+	dst = filepath.Join(dir, "gzip.txt")
+	assert.NoError(ioutil.WriteFile(dst+".partial", gz.Bytes()[:20], 0644))
+	assert.NoError(d.download(download{name: "gzip", url: server.URL + "/gzip", path: dst}))
+	data, err = ioutil.ReadFile(dst)
+	assert.NoError(err)
+	assert.Equal(content, data)
+
+	// Checksum mismatch removes the partial download
+	dst = filepath.Join(dir, "corrupt.txt")
+	err = d.download(download{name: "corrupt", url: server.URL + "/corrupt", path: dst})
+	assert.Error(err)
+	assert.Contains(err.Error(), "sha256 mismatch")
+	_, err = os.Stat(dst + ".partial")
+	assert.True(os.IsNotExist(err))
+
+	// Missing artifacts fail without retrying
+	ranges = nil
+	err = d.download(download{name: "missing", url: server.URL + "/missing", path: filepath.Join(dir, "missing")})
+	assert.Error(err)
+	assert.Contains(err.Error(), "Artifact not found")
+	assert.Len(ranges, 1)
+
+	// Server errors are retried by the downloader alone
+	ranges = nil
+	err = d.download(download{name: "unavailable", url: server.URL + "/unavailable", path: filepath.Join(dir, "unavailable")})
+	assert.Error(err)
+	assert.Len(ranges, d.options.MaxRetries+1)
+
+	// Download many, reporting failure if any failed
+	var downloads []download
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		downloads = append(downloads, download{name: name, url: server.URL + "/plain", path: filepath.Join(dir, "many", name)})
+	}
+	assert.True(d.downloadAll(downloads, 2))
+	for _, dl := range downloads {
+		data, err = ioutil.ReadFile(dl.path)
+		assert.NoError(err)
+		assert.Equal(content, data)
+	}
+	downloads = append(downloads, download{name: "missing", url: server.URL + "/missing", path: filepath.Join(dir, "many", "missing")})
+	assert.False(d.downloadAll(downloads, 3))
+}
+
+func TestHumanSize(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("512 B", humanSize(512))
+	assert.Equal("1.5 KiB", humanSize(1536))
+	assert.Equal("3.0 MiB", humanSize(3*1024*1024))
+}
@@ -0,0 +1,320 @@
+package task
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/taskcluster/taskcluster-cli/format"
+	tcclient "github.com/taskcluster/taskcluster-client-go"
+)
+
+// graph is the dependency graph of the tasks in a task group.
+type graph struct {
+	tasks []groupTask
+	index map[string]int
+	// dependents are the indexes of the tasks depending on each task
+	dependents [][]int
+	// critical is the critical path as indexes of tasks, in order, if
+	// computed
+	critical []int
+	now      time.Time
+}
+
+// newGraph returns the graph of tasks, ignoring dependencies on tasks outside
+// the task group.
+func newGraph(tasks []groupTask, now time.Time) *graph {
+	g := &graph{
+		tasks:      tasks,
+		index:      make(map[string]int, len(tasks)),
+		dependents: make([][]int, len(tasks)),
+		now:        now,
+	}
+	for i, gt := range tasks {
+		g.index[gt.TaskID] = i
+	}
+	for i, gt := range tasks {
+		for _, dep := range gt.Dependencies {
+			if j, ok := g.index[dep]; ok {
+				g.dependents[j] = append(g.dependents[j], i)
+			}
+		}
+	}
+	return g
+}
+
+// end returns the time task i was resolved, or now if it hasn't been.
+func (g *graph) end(i int) time.Time {
+	if !g.tasks[i].Resolved.IsZero() {
+		return g.tasks[i].Resolved
+	}
+	return g.now
+}
+
+// duration returns the time the latest run of task i took, or has taken so
+// far, and zero if it hasn't started.
+func (g *graph) duration(i int) time.Duration {
+	if g.tasks[i].Started.IsZero() {
+		return 0
+	}
+	return g.end(i).Sub(g.tasks[i].Started)
+}
+
+// criticalPath computes the path of tasks that determined the wall time of
+// the task group. It starts at the task resolved last and follows the
+// dependency resolved last, which is the one the task waited for, until a
+// task without dependencies in the task group.
+func (g *graph) criticalPath() []int {
+	last := -1
+	for i, gt := range g.tasks {
+		if gt.Started.IsZero() {
+			continue
+		}
+		if last == -1 || g.end(i).After(g.end(last)) {
+			last = i
+		}
+	}
+	if last == -1 {
+		g.critical = []int{}
+		return g.critical
+	}
+
+	path := []int{last}
+	seen := map[int]bool{last: true}
+	for {
+		next := -1
+		for _, dep := range g.tasks[path[len(path)-1]].Dependencies {
+			j, ok := g.index[dep]
+			if !ok || seen[j] || g.tasks[j].Started.IsZero() {
+				continue
+			}
+			if next == -1 || g.end(j).After(g.end(next)) {
+				next = j
+			}
+		}
+		if next == -1 {
+			break
+		}
+		seen[next] = true
+		path = append(path, next)
+	}
+
+	// Reverse to have the path in the order tasks ran
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	g.critical = path
+	return path
+}
+
+// wallTime returns the time from the start of the first task on the critical
+// path to the end of the last one.
+func (g *graph) wallTime() time.Duration {
+	if len(g.critical) == 0 {
+		return 0
+	}
+	return g.end(g.critical[len(g.critical)-1]).Sub(g.tasks[g.critical[0]].Started)
+}
+
+// criticalEdges returns the edges on the critical path, as a set of
+// 'from->to' task indexes.
+func (g *graph) criticalEdges() map[[2]int]bool {
+	edges := make(map[[2]int]bool)
+	for k := 1; k < len(g.critical); k++ {
+		edges[[2]int{g.critical[k-1], g.critical[k]}] = true
+	}
+	return edges
+}
+
+// edges returns all edges as pairs of task indexes, from a dependency to its
+// dependent, ordered by dependency and dependent.
+func (g *graph) edges() [][2]int {
+	var edges [][2]int
+	for i, dependents := range g.dependents {
+		for _, j := range dependents {
+			edges = append(edges, [2]int{i, j})
+		}
+	}
+	return edges
+}
+
+// label returns the label of task i, with its name, state and duration.
+func (g *graph) label(i int) (string, string) {
+	gt := g.tasks[i]
+	name := gt.Name
+	if name == "" {
+		name = gt.TaskID
+	}
+	details := gt.State
+	if d := g.duration(i); d > 0 {
+		details += ", " + (d / time.Second * time.Second).String()
+	}
+	return name, details
+}
+
+var stateColors = map[string]string{
+	"completed":   "#b8e0b8",
+	"failed":      "#f0b0b0",
+	"exception":   "#e0c0f0",
+	"running":     "#b0d0f0",
+	"pending":     "#f0f0b0",
+	"unscheduled": "#e0e0e0",
+}
+
+// dot renders the graph in the Graphviz DOT language.
+func (g *graph) dot(taskGroupID string) []byte {
+	critical := make(map[int]bool)
+	for _, i := range g.critical {
+		critical[i] = true
+	}
+	criticalEdges := g.criticalEdges()
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "digraph %q {\n", taskGroupID)
+	fmt.Fprintf(buf, "  rankdir=LR;\n")
+	fmt.Fprintf(buf, "  node [shape=box, style=filled];\n")
+	for i, gt := range g.tasks {
+		name, details := g.label(i)
+		attrs := fmt.Sprintf("label=%q, tooltip=%q, fillcolor=%q", name+"\n"+details, gt.TaskID, stateColors[gt.State])
+		if critical[i] {
+			attrs += ", penwidth=3, color=red"
+		}
+		fmt.Fprintf(buf, "  %q [%s];\n", gt.TaskID, attrs)
+	}
+	for _, e := range g.edges() {
+		attrs := ""
+		if criticalEdges[e] {
+			attrs = " [penwidth=3, color=red]"
+		}
+		fmt.Fprintf(buf, "  %q -> %q%s;\n", g.tasks[e[0]].TaskID, g.tasks[e[1]].TaskID, attrs)
+	}
+	fmt.Fprintf(buf, "}\n")
+	return buf.Bytes()
+}
+
+// mermaid renders the graph as a mermaid flowchart.
+func (g *graph) mermaid() []byte {
+	criticalEdges := g.criticalEdges()
+	quote := func(s string) string {
+		return strings.Replace(s, `"`, "#quot;", -1)
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "graph LR\n")
+	for i := range g.tasks {
+		name, details := g.label(i)
+		fmt.Fprintf(buf, "  n%d[\"%s<br/>%s\"]\n", i, quote(name), quote(details))
+	}
+	var critical []string
+	for k, e := range g.edges() {
+		fmt.Fprintf(buf, "  n%d --> n%d\n", e[0], e[1])
+		if criticalEdges[e] {
+			critical = append(critical, fmt.Sprint(k))
+		}
+	}
+	var states []string
+	for state := range stateColors {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+	for _, state := range states {
+		fmt.Fprintf(buf, "  classDef %s fill:%s\n", state, stateColors[state])
+	}
+	for i, gt := range g.tasks {
+		if _, ok := stateColors[gt.State]; ok {
+			fmt.Fprintf(buf, "  class n%d %s\n", i, gt.State)
+		}
+	}
+	for _, i := range g.critical {
+		fmt.Fprintf(buf, "  style n%d stroke:red,stroke-width:3px\n", i)
+	}
+	if len(critical) > 0 {
+		fmt.Fprintf(buf, "  linkStyle %s stroke:red,stroke-width:3px\n", strings.Join(critical, ","))
+	}
+	return buf.Bytes()
+}
+
+// json renders the graph as a JSON document of nodes and edges.
+func (g *graph) json(withCritical bool) ([]byte, error) {
+	nodes := []interface{}{}
+	for i, gt := range g.tasks {
+		node := map[string]interface{}{
+			"taskId":       gt.TaskID,
+			"name":         gt.Name,
+			"state":        gt.State,
+			"dependencies": gt.Dependencies,
+			"duration":     g.duration(i).Seconds(),
+		}
+		if gt.Dependencies == nil {
+			node["dependencies"] = []string{}
+		}
+		if !gt.Started.IsZero() {
+			node["started"] = gt.Started
+		}
+		if !gt.Resolved.IsZero() {
+			node["resolved"] = gt.Resolved
+		}
+		nodes = append(nodes, node)
+	}
+	edges := []interface{}{}
+	for _, e := range g.edges() {
+		edges = append(edges, map[string]interface{}{
+			"from": g.tasks[e[0]].TaskID,
+			"to":   g.tasks[e[1]].TaskID,
+		})
+	}
+	doc := map[string]interface{}{"nodes": nodes, "edges": edges}
+	if withCritical {
+		path := []string{}
+		for _, i := range g.critical {
+			path = append(path, g.tasks[i].TaskID)
+		}
+		doc["criticalPath"] = path
+		doc["wallTime"] = g.wallTime().Seconds()
+	}
+	return format.JSON(doc)
+}
+
+func (t task) runGroupGraph(credentials *tcclient.Credentials, args arguments) bool {
+	taskGroupID := args["<taskGroupId>"].(string)
+
+	tasks, err := t.groupTasks(credentials, taskGroupID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return false
+	}
+	g := newGraph(tasks, time.Now())
+
+	withCritical := args["--critical-path"] == true
+	if withCritical {
+		g.criticalPath()
+		var names []string
+		for _, i := range g.critical {
+			name, details := g.label(i)
+			names = append(names, fmt.Sprintf("%s (%s)", name, details))
+		}
+		fmt.Fprintf(os.Stderr, "Critical path of %d tasks, %s wall time:\n  %s\n",
+			len(g.critical), g.wallTime()/time.Second*time.Second, strings.Join(names, "\n  "))
+	}
+
+	f, _ := args["--format"].(string)
+	var data []byte
+	switch f {
+	case "", "dot":
+		data = g.dot(taskGroupID)
+	case "mermaid":
+		data = g.mermaid()
+	case "json":
+		if data, err = g.json(withCritical); err != nil {
+			panic(fmt.Sprintf("internal error: could not render graph: %v", err))
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "error: unsupported graph format: %s\n", f)
+		return false
+	}
+	os.Stdout.Write(data)
+	return true
+}
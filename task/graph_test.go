@@ -0,0 +1,81 @@
+package task
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func testGraph() *graph {
+	start := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+	return newGraph([]groupTask{
+		{TaskID: "decision", Name: "Decision Task", State: "completed", Started: at(0), Resolved: at(5)},
+		{TaskID: "build", Name: "build", State: "completed", Dependencies: []string{"decision"}, Started: at(6), Resolved: at(60)},
+		{TaskID: "lint", Name: "lint", State: "completed", Dependencies: []string{"decision"}, Started: at(6), Resolved: at(10)},
+		{TaskID: "test-1", Name: "test \"1\"", State: "failed", Dependencies: []string{"build", "lint"}, Started: at(62), Resolved: at(100)},
+		{TaskID: "test-2", Name: "test 2", State: "running", Dependencies: []string{"build", "other-group"}, Started: at(61)},
+		{TaskID: "upload", Name: "upload", State: "unscheduled", Dependencies: []string{"test-1", "test-2"}},
+	}, at(90))
+}
+
+func TestCriticalPath(t *testing.T) {
+	assert := assert.New(t)
+
+	g := testGraph()
+	assert.Equal([]int{0, 1, 3}, g.criticalPath())
+	assert.Equal(100*time.Minute, g.wallTime())
+	assert.Equal(29*time.Minute, g.duration(4))
+	assert.Equal(time.Duration(0), g.duration(5))
+
+	g = newGraph([]groupTask{{TaskID: "a", State: "pending"}}, time.Now())
+	assert.Equal([]int{}, g.criticalPath())
+	assert.Equal(time.Duration(0), g.wallTime())
+}
+
+func TestGraphFormats(t *testing.T) {
+	assert := assert.New(t)
+
+	g := testGraph()
+	g.criticalPath()
+
+	dot := string(g.dot("group"))
+	assert.True(strings.HasPrefix(dot, "digraph \"group\" {\n"))
+	assert.Contains(dot, "  \"build\" [label=\"build\\ncompleted, 54m0s\", tooltip=\"build\", fillcolor=\"#b8e0b8\", penwidth=3, color=red];\n")
+	assert.Contains(dot, "  \"decision\" -> \"build\" [penwidth=3, color=red];\n")
+	assert.Contains(dot, "  \"decision\" -> \"lint\";\n")
+	assert.NotContains(dot, "other-group")
+
+	mermaid := string(g.mermaid())
+	assert.True(strings.HasPrefix(mermaid, "graph LR\n"))
+	assert.Contains(mermaid, "  n3[\"test #quot;1#quot;<br/>failed, 38m0s\"]\n")
+	assert.Contains(mermaid, "  n1 --> n3\n")
+	assert.Contains(mermaid, "  class n5 unscheduled\n")
+	assert.Contains(mermaid, "  linkStyle 0,2 stroke:red,stroke-width:3px\n")
+
+	data, err := g.json(true)
+	assert.NoError(err)
+	var doc struct {
+		Nodes []struct {
+			TaskID       string   `json:"taskId"`
+			Dependencies []string `json:"dependencies"`
+			Duration     float64  `json:"duration"`
+		} `json:"nodes"`
+		Edges        []map[string]string `json:"edges"`
+		CriticalPath []string            `json:"criticalPath"`
+		WallTime     float64             `json:"wallTime"`
+	}
+	assert.NoError(json.Unmarshal(data, &doc))
+	assert.Len(doc.Nodes, 6)
+	assert.Equal([]string{}, doc.Nodes[0].Dependencies)
+	assert.Equal(float64(54*60), doc.Nodes[1].Duration)
+	assert.Len(doc.Edges, 7)
+	assert.Equal(map[string]string{"from": "decision", "to": "build"}, doc.Edges[0])
+	assert.Equal([]string{"decision", "build", "test-1"}, doc.CriticalPath)
+	assert.Equal(float64(100*60), doc.WallTime)
+}
@@ -0,0 +1,471 @@
+package task
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/taskcluster/taskcluster-cli/extpoints"
+	"github.com/taskcluster/taskcluster-cli/format"
+
+	tcclient "github.com/taskcluster/taskcluster-client-go"
+)
+
+func init() {
+	extpoints.Register("group", group{})
+}
+
+// group provides the task group subcommands, sharing the queue helpers of
+// the task subcommands.
+type group struct{}
+
+func (group) ConfigOptions() map[string]extpoints.ConfigOption {
+	return nil
+}
+
+func (group) Summary() string {
+	return "Task group related actions."
+}
+
+func (group) UsesCredentials() bool {
+	return true
+}
+
+func (group) Usage() string {
+	return `Task group related actions.
+
+Usage:
+  taskcluster group list [--name <regex>] [--worker-type <workerType>] [--state <state>] [--format <format>] [--] <taskGroupId>
+  taskcluster group status [--name <regex>] [--worker-type <workerType>] [--format <format>] [--] <taskGroupId>
+  taskcluster group cancel [--name <regex>] [--worker-type <workerType>] [--jobs N] [--rate <n>] [--] <taskGroupId>
+  taskcluster group rerun-failed [--name <regex>] [--worker-type <workerType>] [--jobs N] [--rate <n>] [--] <taskGroupId>
+  taskcluster group artifacts [--name <regex>] [--worker-type <workerType>] [--jobs N] [--format <format>] [--] <taskGroupId>
+  taskcluster group graph [--format <format>] [--critical-path] [--] <taskGroupId>
+  taskcluster group wait [--timeout <duration>] [--interval <duration>] [--] <taskGroupId>
+
+Options:
+  --name <regex>              Only include tasks with names matching <regex>
+  --worker-type <workerType>  Only include tasks for <workerType>
+  --state <state>             Only include tasks in <state>, e.g. failed
+  --format <format>           Output format, table, json or yaml for lists and dot,
+                              json or mermaid for graphs. Defaults to table and dot
+  --critical-path             Highlight the tasks that determined the wall time
+  --jobs N                    Number of concurrent requests to the queue [default: 8]
+  --rate <n>                  Maximum number of requests to the queue per second [default: 20]
+  --timeout <duration>        Stop waiting after <duration>, e.g. '2 hours'
+  --interval <duration>       Time between the first polls of the queue, increased while
+                              nothing changes [default: 5 seconds]
+
+Cancel cancels all tasks that are not resolved, rerun-failed reruns the tasks
+that failed or were resolved as exception. Artifacts lists the artifacts of
+the latest run of each task.
+
+Graph renders the dependencies between the tasks in the group, with the state
+and duration of each task. The critical path follows the dependency resolved
+last back from the task resolved last, which shows what the group waited for.
+
+Waiting prints the tasks that change state, until no task in the group is
+pending or running. It exits with 0 if all tasks completed, 2 if any task
+failed, 3 if any task was resolved as exception and 4 on timeout.
+`
+}
+
+func (group) Execute(context extpoints.Context) bool {
+	args := context.Arguments
+	t := task{queueBaseURL: queueBaseURL(context), rootURL: context.RootURL, fail: context.Fail}
+
+	if args["list"].(bool) {
+		return executeSubCommand(context, t.runGroupList)
+	}
+	if args["status"].(bool) {
+		return executeSubCommand(context, t.runGroupStatus)
+	}
+	if args["cancel"].(bool) {
+		return executeSubCommand(context, t.runGroupCancel)
+	}
+	if args["rerun-failed"].(bool) {
+		return executeSubCommand(context, t.runGroupRerunFailed)
+	}
+	if args["artifacts"].(bool) {
+		return executeSubCommand(context, t.runGroupArtifacts)
+	}
+	if args["graph"].(bool) {
+		return executeSubCommand(context, t.runGroupGraph)
+	}
+	if args["wait"].(bool) {
+		return executeSubCommand(context, t.runGroupWait)
+	}
+
+	return false
+}
+
+// groupTask is a task in a task group.
+type groupTask struct {
+	TaskID       string
+	Name         string
+	WorkerType   string
+	State        string
+	Runs         int
+	Dependencies []string
+	// Requires is the task's requires, all-completed or all-resolved
+	Requires string
+	Deadline time.Time
+	// Started and Resolved are the times the latest run started and was
+	// resolved, zero if it hasn't
+	Started  time.Time
+	Resolved time.Time
+}
+
+// groupTasks returns the tasks in the task group, paging through
+// listTaskGroup.
+func (t task) groupTasks(credentials *tcclient.Credentials, taskGroupID string) ([]groupTask, error) {
+	q := t.queue(credentials)
+	var tasks []groupTask
+	continuation := ""
+	for {
+		l, err := q.ListTaskGroup(taskGroupID, continuation, "")
+		if err != nil {
+			return nil, fmt.Errorf("could not list the task group %s: %v", taskGroupID, err)
+		}
+		for _, ts := range l.Tasks {
+			gt := groupTask{
+				TaskID:       ts.Status.TaskID,
+				Name:         ts.Task.Metadata.Name,
+				WorkerType:   ts.Status.WorkerType,
+				State:        ts.Status.State,
+				Runs:         len(ts.Status.Runs),
+				Dependencies: ts.Task.Dependencies,
+				Requires:     ts.Task.Requires,
+				Deadline:     time.Time(ts.Task.Deadline),
+			}
+			if gt.Runs > 0 {
+				run := ts.Status.Runs[gt.Runs-1]
+				gt.Started = time.Time(run.Started)
+				gt.Resolved = time.Time(run.Resolved)
+			}
+			tasks = append(tasks, gt)
+		}
+		continuation = l.ContinuationToken
+		if continuation == "" {
+			return tasks, nil
+		}
+	}
+}
+
+// selectedTasks returns the tasks in the task group matching the --name,
+// --worker-type and --state arguments.
+func (t task) selectedTasks(credentials *tcclient.Credentials, args arguments) ([]groupTask, bool) {
+	taskGroupID := args["<taskGroupId>"].(string)
+
+	var name *regexp.Regexp
+	if s, ok := args["--name"].(string); ok {
+		var err error
+		if name, err = regexp.Compile(s); err != nil {
+			fmt.Fprintf(os.Stderr, "error: invalid regular expression for --name: %v\n", err)
+			return nil, false
+		}
+	}
+	workerType, _ := args["--worker-type"].(string)
+	state, _ := args["--state"].(string)
+
+	tasks, err := t.groupTasks(credentials, taskGroupID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return nil, false
+	}
+	return filterTasks(tasks, name, workerType, state), true
+}
+
+// filterTasks returns the tasks with names matching name, if not nil, and
+// with workerType and state, if not empty.
+func filterTasks(tasks []groupTask, name *regexp.Regexp, workerType, state string) []groupTask {
+	var result []groupTask
+	for _, gt := range tasks {
+		if (name == nil || name.MatchString(gt.Name)) &&
+			(workerType == "" || gt.WorkerType == workerType) &&
+			(state == "" || gt.State == state) {
+			result = append(result, gt)
+		}
+	}
+	return result
+}
+
+// printFormatted prints value in the format given by --format.
+func printFormatted(args arguments, value interface{}) bool {
+	f, ok := args["--format"].(string)
+	if !ok {
+		f = "table"
+	}
+	data, err := format.Format(f, value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return false
+	}
+	os.Stdout.Write(data)
+	return true
+}
+
+func (t task) runGroupList(credentials *tcclient.Credentials, args arguments) bool {
+	tasks, ok := t.selectedTasks(credentials, args)
+	if !ok {
+		return false
+	}
+
+	rows := []interface{}{}
+	for _, gt := range tasks {
+		rows = append(rows, map[string]interface{}{
+			"taskId":     gt.TaskID,
+			"name":       gt.Name,
+			"workerType": gt.WorkerType,
+			"state":      gt.State,
+		})
+	}
+	return printFormatted(args, rows)
+}
+
+func (t task) runGroupStatus(credentials *tcclient.Credentials, args arguments) bool {
+	tasks, ok := t.selectedTasks(credentials, args)
+	if !ok {
+		return false
+	}
+
+	counts := map[string]interface{}{"total": len(tasks)}
+	for _, gt := range tasks {
+		n, _ := counts[gt.State].(int)
+		counts[gt.State] = n + 1
+	}
+	return printFormatted(args, counts)
+}
+
+// forEachTask calls fn for each task using a pool of --jobs workers, making
+// at most --rate calls per second. It returns false if any call failed.
+func forEachTask(args arguments, tasks []groupTask, fn func(gt groupTask) error) bool {
+	jobs, err := strconv.Atoi(args["--jobs"].(string))
+	if err != nil || jobs < 1 {
+		fmt.Fprintf(os.Stderr, "error: invalid number of jobs: %v\n", args["--jobs"])
+		return false
+	}
+	rate, err := strconv.ParseFloat(args["--rate"].(string), 64)
+	if err != nil || rate <= 0 {
+		fmt.Fprintf(os.Stderr, "error: invalid rate: %v\n", args["--rate"])
+		return false
+	}
+
+	limit := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer limit.Stop()
+	work := make(chan groupTask)
+	var failed int32
+	var wg sync.WaitGroup
+	for i := 0; i < jobs && i < len(tasks); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for gt := range work {
+				if err := fn(gt); err != nil {
+					fmt.Fprintf(os.Stderr, "error: %s: %v\n", gt.TaskID, err)
+					atomic.AddInt32(&failed, 1)
+				}
+			}
+		}()
+	}
+	for _, gt := range tasks {
+		<-limit.C
+		work <- gt
+	}
+	close(work)
+	wg.Wait()
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "error: failed for %d of %d tasks\n", failed, len(tasks))
+		return false
+	}
+	return true
+}
+
+func (t task) runGroupCancel(credentials *tcclient.Credentials, args arguments) bool {
+	tasks, ok := t.selectedTasks(credentials, args)
+	if !ok {
+		return false
+	}
+
+	var unresolved []groupTask
+	for _, gt := range tasks {
+		if !isResolved(gt.State) {
+			unresolved = append(unresolved, gt)
+		}
+	}
+	q := t.queue(credentials)
+	var cancelled int32
+	ok = forEachTask(args, unresolved, func(gt groupTask) error {
+		c, err := q.CancelTask(gt.TaskID)
+		if err != nil {
+			return fmt.Errorf("could not cancel the task: %v", err)
+		}
+		atomic.AddInt32(&cancelled, 1)
+		fmt.Printf("%s %s\n", gt.TaskID, c.Status.State)
+		return nil
+	})
+	fmt.Fprintf(os.Stderr, "Cancelled %d of %d tasks\n", cancelled, len(unresolved))
+	return ok
+}
+
+func (t task) runGroupRerunFailed(credentials *tcclient.Credentials, args arguments) bool {
+	tasks, ok := t.selectedTasks(credentials, args)
+	if !ok {
+		return false
+	}
+
+	var failed []groupTask
+	for _, gt := range tasks {
+		if gt.State == "failed" || gt.State == "exception" {
+			failed = append(failed, gt)
+		}
+	}
+	q := t.queue(credentials)
+	var reran int32
+	ok = forEachTask(args, failed, func(gt groupTask) error {
+		c, err := q.RerunTask(gt.TaskID)
+		if err != nil {
+			return fmt.Errorf("could not rerun the task: %v", err)
+		}
+		atomic.AddInt32(&reran, 1)
+		fmt.Printf("%s %s\n", gt.TaskID, c.Status.State)
+		return nil
+	})
+	fmt.Fprintf(os.Stderr, "Reran %d of %d tasks\n", reran, len(failed))
+	return ok
+}
+
+func (t task) runGroupArtifacts(credentials *tcclient.Credentials, args arguments) bool {
+	tasks, ok := t.selectedTasks(credentials, args)
+	if !ok {
+		return false
+	}
+
+	var withRuns []groupTask
+	for _, gt := range tasks {
+		if gt.Runs > 0 {
+			withRuns = append(withRuns, gt)
+		}
+	}
+	q := t.queue(credentials)
+	var mu sync.Mutex
+	artifacts := make(map[string][]interface{})
+	ok = forEachTask(args, withRuns, func(gt groupTask) error {
+		var rows []interface{}
+		continuation := ""
+		for {
+			a, err := q.ListArtifacts(gt.TaskID, fmt.Sprint(gt.Runs-1), continuation, "")
+			if err != nil {
+				return fmt.Errorf("could not fetch artifacts: %v", err)
+			}
+			for _, ar := range a.Artifacts {
+				rows = append(rows, map[string]interface{}{
+					"taskId":      gt.TaskID,
+					"runId":       gt.Runs - 1,
+					"name":        ar.Name,
+					"storageType": ar.StorageType,
+					"contentType": ar.ContentType,
+				})
+			}
+			continuation = a.ContinuationToken
+			if continuation == "" {
+				break
+			}
+		}
+		mu.Lock()
+		artifacts[gt.TaskID] = rows
+		mu.Unlock()
+		return nil
+	})
+	if !ok {
+		return false
+	}
+
+	// Print in the order of the task group
+	rows := []interface{}{}
+	for _, gt := range withRuns {
+		rows = append(rows, artifacts[gt.TaskID]...)
+	}
+	return printFormatted(args, rows)
+}
+
+// groupResolved returns true, if no task in the task group will still
+// resolve: every task is resolved, or unscheduled and blocked.
+func groupResolved(tasks []groupTask, now time.Time) bool {
+	byID := make(map[string]groupTask, len(tasks))
+	for _, gt := range tasks {
+		byID[gt.TaskID] = gt
+	}
+	blocked := make(map[string]bool, len(tasks))
+	// isBlocked returns true, if the unscheduled task won't be scheduled, as
+	// a dependency failed or won't be scheduled either, or it is past its
+	// deadline and the queue is about to resolve it
+	var isBlocked func(gt groupTask) bool
+	isBlocked = func(gt groupTask) bool {
+		if b, ok := blocked[gt.TaskID]; ok {
+			return b
+		}
+		blocked[gt.TaskID] = false
+		b := !gt.Deadline.IsZero() && !now.Before(gt.Deadline)
+		// Tasks requiring all-resolved are scheduled once the dependencies
+		// resolve, however they do. Dependencies outside of the task group
+		// aren't known, they may still resolve.
+		for _, id := range gt.Dependencies {
+			dep, ok := byID[id]
+			if b || !ok || gt.Requires == "all-resolved" {
+				continue
+			}
+			b = dep.State == "failed" || dep.State == "exception" ||
+				dep.State == "unscheduled" && isBlocked(dep)
+		}
+		blocked[gt.TaskID] = b
+		return b
+	}
+	for _, gt := range tasks {
+		if !isResolved(gt.State) && (gt.State != "unscheduled" || !isBlocked(gt)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t task) runGroupWait(credentials *tcclient.Credentials, args arguments) bool {
+	taskGroupID := args["<taskGroupId>"].(string)
+
+	w, err := newWaiter(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return false
+	}
+	w.summarize = true
+	var tasks []groupTask
+	states, timedOut, err := w.wait(func() (map[string]string, error) {
+		var err error
+		if tasks, err = t.groupTasks(credentials, taskGroupID); err != nil {
+			return nil, err
+		}
+		states := make(map[string]string, len(tasks))
+		for _, gt := range tasks {
+			states[gt.TaskID] = gt.State
+		}
+		return states, nil
+	}, func(states map[string]string) bool {
+		return len(tasks) > 0 && groupResolved(tasks, w.now())
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return false
+	}
+	if !timedOut {
+		fmt.Printf("Task group %s resolved, %s\n", taskGroupID, summary(states))
+	}
+	if code := exitCode(states, timedOut); code != 0 {
+		return t.fail(code)
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package task
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+// groupServer is a stand-in for the queue serving a task group in two pages,
+// recording the tasks cancelled and rerun.
+func groupServer() (*httptest.Server, *[]string) {
+	var mu sync.Mutex
+	var calls []string
+	task := func(taskID, name, workerType, state string, runs int) map[string]interface{} {
+		r := []map[string]interface{}{}
+		for i := 0; i < runs; i++ {
+			r = append(r, map[string]interface{}{"runId": i})
+		}
+		return map[string]interface{}{
+			"status": map[string]interface{}{"taskId": taskID, "workerType": workerType, "state": state, "runs": r},
+			"task":   map[string]interface{}{"metadata": map[string]interface{}{"name": name}},
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var response interface{}
+		switch {
+		case r.URL.Path == "/task-group/g/list" && r.URL.Query().Get("continuationToken") == "":
+			response = map[string]interface{}{"continuationToken": "next", "tasks": []interface{}{
+				task("t1", "build linux", "b-linux", "completed", 1),
+				task("t2", "build windows", "b-win", "failed", 1),
+				task("t3", "test linux", "t-linux", "running", 1),
+			}}
+		case r.URL.Path == "/task-group/g/list":
+			response = map[string]interface{}{"tasks": []interface{}{
+				task("t4", "test windows", "t-win", "exception", 2),
+				task("t5", "lint", "t-linux", "unscheduled", 0),
+			}}
+		case r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/task/"):
+			calls = append(calls, strings.TrimPrefix(r.URL.Path, "/task/"))
+			response = map[string]interface{}{"status": map[string]interface{}{"state": "exception"}}
+		case strings.HasSuffix(r.URL.Path, "/artifacts"):
+			response = map[string]interface{}{"artifacts": []interface{}{
+				map[string]interface{}{"name": "public/logs/live.log", "storageType": "reference"},
+			}}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Not found"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	return server, &calls
+}
+
+func TestGroupTasks(t *testing.T) {
+	assert := assert.New(t)
+
+	server, _ := groupServer()
+	defer server.Close()
+	tk := task{queueBaseURL: server.URL}
+
+	tasks, err := tk.groupTasks(nil, "g")
+	assert.NoError(err)
+	assert.Len(tasks, 5)
+	assert.Equal(groupTask{TaskID: "t4", Name: "test windows", WorkerType: "t-win", State: "exception", Runs: 2}, tasks[3])
+
+	ids := func(tasks []groupTask) []string {
+		var result []string
+		for _, gt := range tasks {
+			result = append(result, gt.TaskID)
+		}
+		return result
+	}
+	assert.Equal([]string{"t1", "t3"}, ids(filterTasks(tasks, regexp.MustCompile("linux$"), "", "")))
+	assert.Equal([]string{"t3", "t5"}, ids(filterTasks(tasks, nil, "t-linux", "")))
+	assert.Equal([]string{"t2"}, ids(filterTasks(tasks, regexp.MustCompile("^build"), "", "failed")))
+
+	_, err = tk.groupTasks(nil, "missing")
+	assert.Error(err)
+}
+
+func TestGroupCancelAndRerun(t *testing.T) {
+	assert := assert.New(t)
+
+	server, calls := groupServer()
+	defer server.Close()
+	tk := task{queueBaseURL: server.URL}
+	args := func(command string) arguments {
+		return arguments{"<taskGroupId>": "g", "--jobs": "2", "--rate": "1000", command: true}
+	}
+
+	assert.True(tk.runGroupCancel(nil, args("cancel")))
+	sort.Strings(*calls)
+	assert.Equal([]string{"t3/cancel", "t5/cancel"}, *calls)
+
+	*calls = nil
+	assert.True(tk.runGroupRerunFailed(nil, args("rerun-failed")))
+	sort.Strings(*calls)
+	assert.Equal([]string{"t2/rerun", "t4/rerun"}, *calls)
+
+	a := args("cancel")
+	a["--jobs"] = "0"
+	assert.False(tk.runGroupCancel(nil, a))
+}
+
+func TestGroupResolved(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	tasks := func(ts ...groupTask) []groupTask { return ts }
+	build := groupTask{TaskID: "build", State: "failed"}
+	test := groupTask{TaskID: "test", State: "unscheduled", Dependencies: []string{"build"}}
+	upload := groupTask{TaskID: "upload", State: "unscheduled", Dependencies: []string{"test"}}
+
+	assert.True(groupResolved(tasks(build, test, upload), now))
+	assert.False(groupResolved(tasks(groupTask{TaskID: "build", State: "running"}, test), now))
+
+	// Tasks requiring all-resolved are still scheduled
+	cleanup := test
+	cleanup.Requires = "all-resolved"
+	assert.False(groupResolved(tasks(build, cleanup), now))
+
+	// Unscheduled tasks without failed dependencies may still be scheduled,
+	// unless they are past their deadline
+	external := groupTask{TaskID: "external", State: "unscheduled", Dependencies: []string{"other-group"}}
+	assert.False(groupResolved(tasks(external), now))
+	external.Deadline = now
+	assert.True(groupResolved(tasks(external), now))
+}
+
+func TestGroupWait(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		task := func(taskID, state string, dependencies ...string) map[string]interface{} {
+			return map[string]interface{}{
+				"status": map[string]interface{}{"taskId": taskID, "state": state},
+				"task":   map[string]interface{}{"dependencies": dependencies},
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"tasks": []interface{}{
+			task("t1", "completed"),
+			task("t2", "failed", "t1"),
+			task("t3", "unscheduled", "t2"),
+		}})
+	}))
+	defer server.Close()
+
+	code := 0
+	tk := task{queueBaseURL: server.URL, fail: failRecorder(&code)}
+	assert.False(tk.runGroupWait(nil, arguments{"<taskGroupId>": "g", "--interval": "1 second"}))
+	assert.Equal(exitFailed, code)
+}
@@ -0,0 +1,250 @@
+package task
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/taskcluster/taskcluster-cli/format"
+	tcclient "github.com/taskcluster/taskcluster-client-go"
+	"github.com/taskcluster/taskcluster-client-go/queue"
+)
+
+// inspection is the summary of a task shown by inspect.
+type inspection struct {
+	TaskID        string                `json:"taskId"`
+	Name          string                `json:"name"`
+	Description   string                `json:"description"`
+	Owner         string                `json:"owner"`
+	Source        string                `json:"source"`
+	ProvisionerID string                `json:"provisionerId"`
+	WorkerType    string                `json:"workerType"`
+	TaskGroupID   string                `json:"taskGroupId"`
+	State         string                `json:"state"`
+	Created       tcclient.Time         `json:"created"`
+	Deadline      tcclient.Time         `json:"deadline"`
+	Expires       tcclient.Time         `json:"expires"`
+	Dependencies  []inspectedDependency `json:"dependencies"`
+	Scopes        []string              `json:"scopes"`
+	Routes        []string              `json:"routes"`
+	Runs          []inspectedRun        `json:"runs"`
+}
+
+type inspectedDependency struct {
+	TaskID string `json:"taskId"`
+	State  string `json:"state"`
+}
+
+type inspectedRun struct {
+	RunID          int        `json:"runId"`
+	State          string     `json:"state"`
+	ReasonCreated  string     `json:"reasonCreated"`
+	ReasonResolved string     `json:"reasonResolved,omitempty"`
+	WorkerGroup    string     `json:"workerGroup,omitempty"`
+	WorkerID       string     `json:"workerId,omitempty"`
+	Scheduled      *time.Time `json:"scheduled,omitempty"`
+	Started        *time.Time `json:"started,omitempty"`
+	Resolved       *time.Time `json:"resolved,omitempty"`
+	Artifacts      int        `json:"artifacts"`
+}
+
+func (t task) runInspect(credentials *tcclient.Credentials, args arguments) bool {
+	q := t.queue(credentials)
+	taskID := args["<taskId>"].(string)
+
+	i, err := inspect(q, taskID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return false
+	}
+
+	if f, ok := args["--format"].(string); ok {
+		data, err := format.Format(f, toDocument(i))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return false
+		}
+		os.Stdout.Write(data)
+		return true
+	}
+	i.print(os.Stdout, time.Now())
+	return true
+}
+
+// inspect fetches the definition and status of the task, then the states of
+// its dependencies and the number of artifacts of each run, concurrently.
+func inspect(q *queue.Queue, taskID string) (*inspection, error) {
+	var def *queue.TaskDefinitionResponse
+	var status *queue.TaskStatusResponse
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []string
+	fail := func(msg string, a ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, fmt.Sprintf(msg, a...))
+	}
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		var err error
+		if def, err = q.Task(taskID); err != nil {
+			fail("could not get the task %s: %v", taskID, err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		var err error
+		if status, err = q.Status(taskID); err != nil {
+			fail("could not get the status of the task %s: %v", taskID, err)
+		}
+	}()
+	wg.Wait()
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+
+	i := &inspection{
+		TaskID:        taskID,
+		Name:          def.Metadata.Name,
+		Description:   def.Metadata.Description,
+		Owner:         def.Metadata.Owner,
+		Source:        def.Metadata.Source,
+		ProvisionerID: def.ProvisionerID,
+		WorkerType:    def.WorkerType,
+		TaskGroupID:   def.TaskGroupID,
+		State:         status.Status.State,
+		Created:       def.Created,
+		Deadline:      def.Deadline,
+		Expires:       def.Expires,
+		Scopes:        def.Scopes,
+		Routes:        def.Routes,
+	}
+
+	i.Dependencies = make([]inspectedDependency, len(def.Dependencies))
+	for k, dep := range def.Dependencies {
+		i.Dependencies[k].TaskID = dep
+		wg.Add(1)
+		go func(d *inspectedDependency) {
+			defer wg.Done()
+			// Dependencies may have expired, that doesn't fail the inspection
+			s, err := q.Status(d.TaskID)
+			if err != nil {
+				d.State = fmt.Sprintf("unknown: %v", err)
+				return
+			}
+			d.State = s.Status.State
+		}(&i.Dependencies[k])
+	}
+
+	i.Runs = make([]inspectedRun, len(status.Status.Runs))
+	for k, run := range status.Status.Runs {
+		r := &i.Runs[k]
+		r.RunID = run.RunID
+		r.State = run.State
+		r.ReasonCreated = run.ReasonCreated
+		r.ReasonResolved = run.ReasonResolved
+		r.WorkerGroup = run.WorkerGroup
+		r.WorkerID = run.WorkerID
+		r.Scheduled = optionalTime(run.Scheduled)
+		r.Started = optionalTime(run.Started)
+		r.Resolved = optionalTime(run.Resolved)
+		wg.Add(1)
+		go func(r *inspectedRun) {
+			defer wg.Done()
+			continuation := ""
+			for {
+				a, err := q.ListArtifacts(taskID, fmt.Sprint(r.RunID), continuation, "")
+				if err != nil {
+					fail("could not fetch artifacts for task %s run %d: %v", taskID, r.RunID, err)
+					return
+				}
+				r.Artifacts += len(a.Artifacts)
+				continuation = a.ContinuationToken
+				if continuation == "" {
+					return
+				}
+			}
+		}(r)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return i, nil
+}
+
+// optionalTime returns nil for the zero time, so it is omitted.
+func optionalTime(t tcclient.Time) *time.Time {
+	if time.Time(t).IsZero() {
+		return nil
+	}
+	u := time.Time(t).UTC()
+	return &u
+}
+
+// print writes the inspection for humans to out, with durations of running
+// runs up to now.
+func (i *inspection) print(out io.Writer, now time.Time) {
+	w := tabwriter.NewWriter(out, 0, 4, 1, ' ', 0)
+	fmt.Fprintf(w, "Task:\t%s\n", i.TaskID)
+	fmt.Fprintf(w, "Name:\t%s\n", i.Name)
+	fmt.Fprintf(w, "Description:\t%s\n", strings.Replace(strings.TrimSpace(i.Description), "\n", "\n\t", -1))
+	fmt.Fprintf(w, "Owner:\t%s\n", i.Owner)
+	fmt.Fprintf(w, "Source:\t%s\n", i.Source)
+	fmt.Fprintf(w, "Worker type:\t%s/%s\n", i.ProvisionerID, i.WorkerType)
+	fmt.Fprintf(w, "Task group:\t%s\n", i.TaskGroupID)
+	fmt.Fprintf(w, "State:\t%s\n", i.State)
+	fmt.Fprintf(w, "Created:\t%s\n", time.Time(i.Created).UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "Deadline:\t%s\n", time.Time(i.Deadline).UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "Expires:\t%s\n", time.Time(i.Expires).UTC().Format(time.RFC3339))
+	w.Flush()
+
+	if len(i.Dependencies) > 0 {
+		fmt.Fprintf(out, "Dependencies:\n")
+		for _, d := range i.Dependencies {
+			fmt.Fprintf(w, "  %s\t%s\n", d.TaskID, d.State)
+		}
+		w.Flush()
+	}
+	for _, section := range []struct {
+		title string
+		items []string
+	}{{"Scopes", i.Scopes}, {"Routes", i.Routes}} {
+		if len(section.items) > 0 {
+			fmt.Fprintf(out, "%s:\n", section.title)
+			for _, item := range section.items {
+				fmt.Fprintf(out, "  %s\n", item)
+			}
+		}
+	}
+
+	if len(i.Runs) > 0 {
+		fmt.Fprintf(out, "Runs:\n")
+		for _, r := range i.Runs {
+			state := r.State
+			if r.ReasonResolved != "" && r.ReasonResolved != r.State {
+				state += " (" + r.ReasonResolved + ")"
+			}
+			worker := "-"
+			if r.WorkerGroup != "" || r.WorkerID != "" {
+				worker = r.WorkerGroup + "/" + r.WorkerID
+			}
+			timing := "not started"
+			if r.Started != nil {
+				end := now
+				if r.Resolved != nil {
+					end = *r.Resolved
+				}
+				timing = fmt.Sprintf("started %s, took %s", r.Started.Format(time.RFC3339), end.Sub(*r.Started)/time.Second*time.Second)
+			}
+			fmt.Fprintf(w, "  %d\t%s\t%s\t%s\t%s\t%d artifacts\n", r.RunID, state, r.ReasonCreated, worker, timing, r.Artifacts)
+		}
+		w.Flush()
+	}
+}
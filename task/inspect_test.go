@@ -0,0 +1,115 @@
+package task
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var res interface{}
+		switch req.URL.Path {
+		case "/task/abc":
+			res = map[string]interface{}{
+				"provisionerId": "aws-provisioner-v1",
+				"workerType":    "tutorial",
+				"taskGroupId":   "abc",
+				"created":       "2017-01-01T00:00:00.000Z",
+				"deadline":      "2017-01-02T00:00:00.000Z",
+				"expires":       "2018-01-01T00:00:00.000Z",
+				"dependencies":  []string{"dep", "expired"},
+				"scopes":        []string{"queue:route:index.*"},
+				"routes":        []string{"index.abc"},
+				"metadata": map[string]string{
+					"name":        "Test",
+					"description": "Tests\nthings",
+					"owner":       "me@example.com",
+					"source":      "https://example.com",
+				},
+			}
+		case "/task/abc/status":
+			res = map[string]interface{}{"status": map[string]interface{}{
+				"state": "running",
+				"runs": []map[string]interface{}{{
+					"runId":          0,
+					"state":          "exception",
+					"reasonCreated":  "scheduled",
+					"reasonResolved": "worker-shutdown",
+					"workerGroup":    "us-east-1",
+					"workerId":       "i-1",
+					"started":        "2017-01-01T00:01:00.000Z",
+					"resolved":       "2017-01-01T00:11:00.000Z",
+				}, {
+					"runId":         1,
+					"state":         "running",
+					"reasonCreated": "retry",
+					"workerGroup":   "us-east-1",
+					"workerId":      "i-2",
+					"started":       "2017-01-01T00:12:00.000Z",
+				}},
+			}}
+		case "/task/dep/status":
+			res = map[string]interface{}{"status": map[string]interface{}{"state": "completed"}}
+		case "/task/abc/runs/0/artifacts":
+			// Artifacts of run 0 come in two pages
+			if req.URL.Query().Get("continuationToken") == "" {
+				res = map[string]interface{}{
+					"artifacts":         []map[string]string{{"name": "a"}, {"name": "b"}},
+					"continuationToken": "next",
+				}
+			} else {
+				res = map[string]interface{}{"artifacts": []map[string]string{{"name": "c"}}}
+			}
+		case "/task/abc/runs/1/artifacts":
+			res = map[string]interface{}{"artifacts": []map[string]string{}}
+		default:
+			http.NotFound(w, req)
+			return
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+	defer server.Close()
+
+	i, err := inspect(task{queueBaseURL: server.URL}.queue(nil), "abc")
+	assert.NoError(err)
+	assert.Equal("Test", i.Name)
+	assert.Equal("aws-provisioner-v1", i.ProvisionerID)
+	assert.Equal("running", i.State)
+	assert.Equal("dep", i.Dependencies[0].TaskID)
+	assert.Equal("completed", i.Dependencies[0].State)
+	assert.Equal("expired", i.Dependencies[1].TaskID)
+	assert.Contains(i.Dependencies[1].State, "unknown: ")
+	assert.Equal(3, i.Runs[0].Artifacts)
+	assert.Equal(0, i.Runs[1].Artifacts)
+	assert.Nil(i.Runs[0].Scheduled)
+	assert.Nil(i.Runs[1].Resolved)
+
+	var out bytes.Buffer
+	i.print(&out, time.Date(2017, 1, 1, 0, 42, 0, 0, time.UTC))
+	lines := strings.Split(out.String(), "\n")
+	assert.Contains(lines, "Description: Tests")
+	assert.Contains(lines, "             things")
+	assert.Contains(lines, "Worker type: aws-provisioner-v1/tutorial")
+	assert.Contains(lines, "  dep     completed")
+	assert.Contains(lines, "  queue:route:index.*")
+	assert.Contains(lines, "  index.abc")
+	assert.Contains(lines, "  0 exception (worker-shutdown) scheduled us-east-1/i-1 started 2017-01-01T00:01:00Z, took 10m0s 3 artifacts")
+	assert.Contains(lines, "  1 running                     retry     us-east-1/i-2 started 2017-01-01T00:12:00Z, took 30m0s 0 artifacts")
+
+	doc := toDocument(i).(map[string]interface{})
+	assert.Equal("abc", doc["taskId"])
+	assert.Equal("2017-01-01T00:11:00Z", doc["runs"].([]interface{})[0].(map[string]interface{})["resolved"])
+
+	// Missing tasks are errors
+	_, err = inspect(task{queueBaseURL: server.URL}.queue(nil), "missing")
+	assert.Error(err)
+}
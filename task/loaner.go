@@ -0,0 +1,202 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/taskcluster/taskcluster-cli/apis"
+	"github.com/taskcluster/taskcluster-cli/client"
+	"github.com/taskcluster/taskcluster-cli/scopes"
+	"github.com/taskcluster/taskcluster-cli/slugid"
+	tcclient "github.com/taskcluster/taskcluster-client-go"
+	"github.com/taskcluster/taskcluster-client-go/queue"
+)
+
+// loanerMaxRunTime is the least maxRunTime of a loaner, so there is time to
+// debug.
+const loanerMaxRunTime = 3 * time.Hour
+
+// interactiveArtifacts are the names of the artifacts created by workers for
+// interactive tasks, without the worker specific prefix.
+var interactiveArtifacts = map[string]string{
+	"shell.html":   "Shell",
+	"display.html": "Display",
+}
+
+func (t task) runLoaner(credentials *tcclient.Credentials, args arguments) bool {
+	q := t.queue(credentials)
+	taskID := args["<taskId>"].(string)
+
+	w, err := newWaiter(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return false
+	}
+
+	definition, err := q.Task(taskID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not get the task %s: %v\n", taskID, err)
+		return false
+	}
+	def := toDocument(definition).(map[string]interface{})
+	now := time.Now()
+	if err := shiftTimes(def, now); err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not update the times of task %s: %v\n", taskID, err)
+		return false
+	}
+	if err := makeInteractive(def, now); err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not make task %s interactive: %v\n", taskID, err)
+		return false
+	}
+
+	loanerID := slugid.Nice()
+	if err := submitTask(q, loanerID, def); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return false
+	}
+	fmt.Printf("Created loaner task %s\n", loanerID)
+	fmt.Println(client.InspectorURL(t.rootURL, loanerID))
+
+	runID, names, timedOut, err := waitInteractive(q, loanerID, w)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return false
+	}
+	if timedOut {
+		return t.fail(exitTimeout)
+	}
+
+	for _, name := range names {
+		u, err := apis.EntryURL("queue", "getArtifact", t.rootURL, map[string]string{
+			"taskId": loanerID,
+			"runId":  fmt.Sprint(runID),
+			"name":   name,
+		}, nil)
+		if err != nil {
+			panic(fmt.Sprintf("internal error: could not construct artifact URL: %v", err))
+		}
+		if credentials != nil {
+			if u, err = clientCredentials(credentials).SignURL(u, loanerMaxRunTime); err != nil {
+				fmt.Fprintf(os.Stderr, "error: could not sign the URL of %s: %v\n", name, err)
+				return false
+			}
+		}
+		fmt.Printf("%s: %s\n", interactiveArtifacts[path.Base(name)], u)
+	}
+	return true
+}
+
+// makeInteractive rewrites the task definition def into a loaner: the
+// interactive feature of docker-worker and generic-worker is enabled along
+// with its scope, the maxRunTime, deadline and expires are extended and
+// dependencies and routes, which would report the loaner like the task, are
+// removed.
+func makeInteractive(def map[string]interface{}, now time.Time) error {
+	payload, ok := def["payload"].(map[string]interface{})
+	if !ok {
+		return errors.New("the payload isn't an object")
+	}
+
+	features, ok := payload["features"].(map[string]interface{})
+	if !ok {
+		features = make(map[string]interface{})
+		payload["features"] = features
+	}
+	features["interactive"] = true
+	if err := setEnv(def, map[string]string{"TASKCLUSTER_INTERACTIVE": "true"}); err != nil {
+		return err
+	}
+
+	maxRunTime, _ := payload["maxRunTime"].(float64)
+	if maxRunTime < loanerMaxRunTime.Seconds() {
+		payload["maxRunTime"] = loanerMaxRunTime.Seconds()
+	}
+	// The deadline must leave time to claim the task and run it, and the
+	// task mustn't expire before it
+	deadline, err := parseTime(def["deadline"])
+	if err != nil {
+		return fmt.Errorf("invalid deadline: %v", err)
+	}
+	if least := now.Add(loanerMaxRunTime + time.Hour); deadline.Before(least) {
+		deadline = least
+		def["deadline"] = tcclient.Time(deadline)
+	}
+	if expires, err := parseTime(def["expires"]); err == nil && expires.Before(deadline) {
+		def["expires"] = tcclient.Time(deadline)
+	}
+
+	// The features must be granted to the task
+	scope := "docker-worker:feature:interactive"
+	if _, ok := payload["image"]; !ok {
+		scope = fmt.Sprintf("generic-worker:interactive:%v/%v", def["provisionerId"], def["workerType"])
+	}
+	taskScopes, _ := def["scopes"].([]interface{})
+	have := make([]string, 0, len(taskScopes))
+	for _, s := range taskScopes {
+		have = append(have, fmt.Sprint(s))
+	}
+	if !scopes.Satisfies(have, scope) {
+		def["scopes"] = append(taskScopes, scope)
+	}
+
+	delete(def, "dependencies")
+	delete(def, "routes")
+	if metadata, ok := def["metadata"].(map[string]interface{}); ok {
+		metadata["name"] = fmt.Sprintf("Loaner for %v", metadata["name"])
+	}
+	return nil
+}
+
+// waitInteractive waits for the task to be running and to have created its
+// interactive artifacts, and returns the run and names of the artifacts.
+func waitInteractive(q *queue.Queue, taskID string, w *waiter) (int, []string, bool, error) {
+	var runID int
+	var names []string
+	states, timedOut, err := w.wait(func() (map[string]string, error) {
+		s, err := q.Status(taskID)
+		if err != nil {
+			return nil, fmt.Errorf("could not get the task %s: %v", taskID, err)
+		}
+		state := s.Status.State
+		if state != "running" {
+			return map[string]string{taskID: state}, nil
+		}
+
+		runID = len(s.Status.Runs) - 1
+		names = nil
+		continuation := ""
+		for {
+			a, err := q.ListArtifacts(taskID, fmt.Sprint(runID), continuation, "")
+			if err != nil {
+				return nil, fmt.Errorf("could not fetch artifacts for task %s run %d: %v", taskID, runID, err)
+			}
+			for _, ar := range a.Artifacts {
+				if _, ok := interactiveArtifacts[path.Base(ar.Name)]; ok {
+					names = append(names, ar.Name)
+				}
+			}
+			continuation = a.ContinuationToken
+			if continuation == "" {
+				break
+			}
+		}
+		if len(names) > 0 {
+			state = "interactive"
+		}
+		return map[string]string{taskID: state}, nil
+	}, func(states map[string]string) bool {
+		return states[taskID] == "interactive" || isResolved(states[taskID])
+	})
+	if err != nil || timedOut {
+		return 0, nil, timedOut, err
+	}
+	if state := states[taskID]; state != "interactive" {
+		return 0, nil, false, fmt.Errorf("task %s was resolved as %s before it was interactive", taskID, state)
+	}
+	sort.Strings(names)
+	return runID, names, false, nil
+}
@@ -0,0 +1,95 @@
+package task
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	tcclient "github.com/taskcluster/taskcluster-client-go"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestMakeInteractive(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2017, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	var def map[string]interface{}
+	assert.NoError(json.Unmarshal([]byte(retriggerTask), &def))
+	def["routes"] = []interface{}{"index.project.build"}
+	assert.NoError(shiftTimes(def, now))
+	assert.NoError(makeInteractive(def, now))
+
+	payload := def["payload"].(map[string]interface{})
+	assert.Equal(map[string]interface{}{"interactive": true}, payload["features"])
+	assert.Equal(map[string]interface{}{"TASKCLUSTER_INTERACTIVE": "true"}, payload["env"])
+	assert.Equal(float64(3*60*60), payload["maxRunTime"])
+	assert.Equal(tcclient.Time(now.AddDate(0, 0, 1)), def["deadline"])
+	assert.Equal(tcclient.Time(now.AddDate(1, 0, 0)), def["expires"])
+	assert.Equal([]interface{}{"generic-worker:interactive:aws-provisioner-v1/tutorial"}, def["scopes"])
+	assert.Nil(def["dependencies"])
+	assert.Nil(def["routes"])
+	assert.Equal("Loaner for hello", def["metadata"].(map[string]interface{})["name"])
+
+	// Longer maxRunTimes are kept, short deadlines and expires extended, and
+	// granted scopes not repeated
+	def = map[string]interface{}{
+		"deadline": tcclient.Time(now.Add(time.Hour)),
+		"expires":  tcclient.Time(now.Add(2 * time.Hour)),
+		"scopes":   []interface{}{"docker-worker:feature:*"},
+		"payload":  map[string]interface{}{"image": "ubuntu", "maxRunTime": float64(86400)},
+	}
+	assert.NoError(makeInteractive(def, now))
+	assert.Equal(float64(86400), def["payload"].(map[string]interface{})["maxRunTime"])
+	assert.Equal(tcclient.Time(now.Add(4*time.Hour)), def["deadline"])
+	assert.Equal(tcclient.Time(now.Add(4*time.Hour)), def["expires"])
+	assert.Equal([]interface{}{"docker-worker:feature:*"}, def["scopes"])
+
+	def["scopes"] = nil
+	assert.NoError(makeInteractive(def, now))
+	assert.Equal([]interface{}{"docker-worker:feature:interactive"}, def["scopes"])
+
+	assert.Error(makeInteractive(map[string]interface{}{}, now))
+}
+
+func TestWaitInteractive(t *testing.T) {
+	assert := assert.New(t)
+
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/task/abc/status":
+			polls++
+			state := "running"
+			if polls == 1 {
+				state = "pending"
+			}
+			w.Write([]byte(`{"status": {"taskId": "abc", "state": "` + state + `", "runs": [{"runId": 0}]}}`))
+		case "/task/abc/runs/0/artifacts":
+			if polls < 3 {
+				w.Write([]byte(`{"artifacts": [{"name": "public/logs/live.log"}]}`))
+				return
+			}
+			w.Write([]byte(`{"artifacts": [
+				{"name": "public/logs/live.log"},
+				{"name": "private/docker-worker/shell.html"},
+				{"name": "private/docker-worker/display.html"}
+			]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	q := task{queueBaseURL: server.URL}.queue(nil)
+	w := &waiter{interval: time.Second, out: ioutil.Discard, now: time.Now, sleep: func(time.Duration) {}}
+
+	runID, names, timedOut, err := waitInteractive(q, "abc", w)
+	assert.NoError(err)
+	assert.False(timedOut)
+	assert.Equal(0, runID)
+	assert.Equal([]string{"private/docker-worker/display.html", "private/docker-worker/shell.html"}, names)
+	assert.Equal(3, polls)
+}
@@ -0,0 +1,290 @@
+package task
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/taskcluster/taskcluster-cli/apis"
+	"github.com/taskcluster/taskcluster-cli/retry"
+	tcclient "github.com/taskcluster/taskcluster-client-go"
+)
+
+const (
+	liveLog    = "public/logs/live.log"
+	backingLog = "public/logs/live_backing.log"
+)
+
+// ansiPattern matches ANSI escape sequences, e.g. for colors.
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;?]*[A-Za-z]")
+
+func (t task) runLog(credentials *tcclient.Credentials, args arguments) bool {
+	q := t.queue(credentials)
+	taskID := args["<taskId>"].(string)
+
+	s, err := q.Status(taskID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not get the task %s: %v\n", taskID, err)
+		return false
+	}
+	if len(s.Status.Runs) == 0 {
+		fmt.Fprintf(os.Stderr, "error: task %s has no runs\n", taskID)
+		return false
+	}
+	runID, err := extractRunID(len(s.Status.Runs)-1, args["--run"])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: invalid runID: %v\n", err)
+		return false
+	}
+
+	urls := make(map[string]string)
+	for _, name := range []string{liveLog, backingLog} {
+		urls[name], err = apis.EntryURL("queue", "getArtifact", t.rootURL, map[string]string{
+			"taskId": taskID,
+			"runId":  fmt.Sprint(runID),
+			"name":   name,
+		}, nil)
+		if err != nil {
+			panic(fmt.Sprintf("internal error: could not construct log URL: %v", err))
+		}
+	}
+
+	out := &logWriter{
+		w:          os.Stdout,
+		stripColor: args["--no-color"] == true,
+		timestamps: args["--timestamps"] == true,
+		now:        time.Now,
+	}
+	f := &logFollower{
+		client:     httpClient(clientCredentials(credentials), retry.DefaultOptions),
+		liveURL:    urls[liveLog],
+		backingURL: urls[backingLog],
+		out:        out,
+		state: func() (string, error) {
+			s, err := q.Status(taskID)
+			if err != nil {
+				return "", err
+			}
+			if runID >= len(s.Status.Runs) {
+				return "", fmt.Errorf("task %s has no run %d", taskID, runID)
+			}
+			return s.Status.Runs[runID].State, nil
+		},
+		interval: 5 * time.Second,
+		idle:     time.Second,
+		sleep:    time.Sleep,
+	}
+
+	if args["--follow"] == true {
+		err = f.follow()
+	} else {
+		err = f.snapshot(s.Status.Runs[runID].State)
+	}
+	out.Flush()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not get the log of task %s run %d: %v\n", taskID, runID, err)
+		return false
+	}
+	return true
+}
+
+// logFollower fetches the log of a run, from the live log while the run is
+// running and from the backing log once it is resolved.
+type logFollower struct {
+	client     *http.Client
+	liveURL    string
+	backingURL string
+	out        io.Writer
+	// state returns the current state of the run
+	state func() (string, error)
+	// interval is the time to wait before reconnecting to the live log or
+	// checking the state of a pending run
+	interval time.Duration
+	// idle is the time without data after which a snapshot of the live log
+	// is complete
+	idle  time.Duration
+	sleep func(time.Duration)
+	// offset is the number of bytes of the log written to out
+	offset int64
+}
+
+// snapshot writes the log as it is now, reading the live log until no data
+// arrives for f.idle if the run is running.
+func (f *logFollower) snapshot(state string) error {
+	switch state {
+	case "running":
+		return f.fetch(f.liveURL, f.idle)
+	case "pending", "unscheduled":
+		return fmt.Errorf("the run is %s and has no log yet", state)
+	default:
+		return f.fetch(f.backingURL, 0)
+	}
+}
+
+// follow streams the live log while the run is running, reconnecting if the
+// connection drops, and writes the rest of the backing log once the run is
+// resolved.
+func (f *logFollower) follow() error {
+	for {
+		state, err := f.state()
+		if err != nil {
+			return err
+		}
+		switch state {
+		case "pending", "unscheduled":
+			f.sleep(f.interval)
+		case "running":
+			offset := f.offset
+			if err := f.fetch(f.liveURL, 0); err != nil {
+				if _, ok := err.(permanentError); ok {
+					return err
+				}
+			}
+			// Wait before reconnecting, if the live log ended without data
+			if f.offset == offset {
+				f.sleep(f.interval)
+			}
+		default:
+			return f.fetch(f.backingURL, 0)
+		}
+	}
+}
+
+// fetch writes the log at url to f.out, skipping the first f.offset bytes,
+// which have been written already. If idle > 0 it stops when no data arrives
+// for idle.
+func (f *logFollower) fetch(url string, idle time.Duration) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return permanentError{err}
+	}
+	if f.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", f.offset))
+	}
+	res, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusPartialContent:
+	case res.StatusCode == http.StatusOK:
+		// The server ignored the range, skip what has been written
+		if _, err := io.CopyN(ioutil.Discard, res.Body, f.offset); err != nil {
+			return err
+		}
+	case res.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// Nothing new
+		return nil
+	case res.StatusCode == http.StatusNotFound:
+		// Log artifacts may not have been created yet
+		return responseError(res)
+	case res.StatusCode/100 == 5:
+		return responseError(res)
+	default:
+		return permanentError{responseError(res)}
+	}
+
+	// Read in the background, so reading can be given up when idle
+	chunks := make(chan []byte)
+	done := make(chan error, 1)
+	go func() {
+		for {
+			buf := make([]byte, 32*1024)
+			n, err := res.Body.Read(buf)
+			if n > 0 {
+				chunks <- buf[:n]
+			}
+			if err != nil {
+				close(chunks)
+				if err == io.EOF {
+					err = nil
+				}
+				done <- err
+				return
+			}
+		}
+	}()
+
+	var timeout <-chan time.Time
+	for {
+		if idle > 0 {
+			timeout = time.After(idle)
+		}
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				return <-done
+			}
+			if _, err := f.out.Write(chunk); err != nil {
+				res.Body.Close()
+				for range chunks {
+				}
+				return permanentError{err}
+			}
+			f.offset += int64(len(chunk))
+		case <-timeout:
+			// Closing the body makes the reader stop
+			res.Body.Close()
+			for range chunks {
+			}
+			return nil
+		}
+	}
+}
+
+// logWriter writes a log to w, removing ANSI escape sequences and prefixing
+// lines with the time they were received, if enabled. Lines are buffered
+// until complete, if either is enabled.
+type logWriter struct {
+	w          io.Writer
+	stripColor bool
+	timestamps bool
+	now        func() time.Time
+	buf        []byte
+}
+
+func (l *logWriter) Write(p []byte) (int, error) {
+	if !l.stripColor && !l.timestamps {
+		return l.w.Write(p)
+	}
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i == -1 {
+			break
+		}
+		if err := l.writeLine(l.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		l.buf = l.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes the last line, if it isn't terminated by a newline.
+func (l *logWriter) Flush() error {
+	if len(l.buf) == 0 {
+		return nil
+	}
+	err := l.writeLine(l.buf)
+	l.buf = nil
+	return err
+}
+
+func (l *logWriter) writeLine(line []byte) error {
+	if l.stripColor {
+		line = ansiPattern.ReplaceAll(line, nil)
+	}
+	if l.timestamps {
+		line = append([]byte(l.now().UTC().Format(time.RFC3339)+" "), line...)
+	}
+	_, err := l.w.Write(line)
+	return err
+}
@@ -0,0 +1,119 @@
+package task
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestLogWriter(t *testing.T) {
+	assert := assert.New(t)
+
+	var out bytes.Buffer
+	l := &logWriter{w: &out}
+	l.Write([]byte("\x1b[32mgreen"))
+	assert.Equal("\x1b[32mgreen", out.String())
+
+	out.Reset()
+	l = &logWriter{
+		w:          &out,
+		stripColor: true,
+		timestamps: true,
+		now:        func() time.Time { return time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC) },
+	}
+	l.Write([]byte("\x1b[1;32mgre"))
+	l.Write([]byte("en\x1b[0m\nsecond "))
+	assert.Equal("2017-01-02T03:04:05Z green\n", out.String())
+	l.Write([]byte("line"))
+	assert.NoError(l.Flush())
+	assert.Equal("2017-01-02T03:04:05Z green\n2017-01-02T03:04:05Z second line", out.String())
+}
+
+func TestLogFollower(t *testing.T) {
+	assert := assert.New(t)
+
+	full := "line 1\nline 2\nline 3\nline 4\n"
+	var mu sync.Mutex
+	state := "pending"
+	connections := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var start int
+		fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &start)
+		switch r.URL.Path {
+		case "/live":
+			// The live log drops the connection after a line
+			connections++
+			if connections >= 3 {
+				state = "completed"
+			}
+			end := len(full)
+			if i := bytes.IndexByte([]byte(full[start:]), '\n'); i != -1 {
+				end = start + i + 1
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/*", start, end-1))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(full[start:end]))
+		case "/backing":
+			// The backing log ignores ranges
+			w.Write([]byte(full))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	sleeps := 0
+	f := &logFollower{
+		client:     http.DefaultClient,
+		liveURL:    server.URL + "/live",
+		backingURL: server.URL + "/backing",
+		out:        &out,
+		state: func() (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if state == "pending" && sleeps > 0 {
+				state = "running"
+			}
+			return state, nil
+		},
+		sleep: func(time.Duration) { sleeps++ },
+	}
+	assert.NoError(f.follow())
+	assert.Equal(full, out.String())
+	assert.Equal(3, connections)
+	assert.Equal(int64(len(full)), f.offset)
+
+	// Snapshot of a resolved run
+	out.Reset()
+	f.offset = 0
+	assert.NoError(f.snapshot("failed"))
+	assert.Equal(full, out.String())
+	assert.Error(f.snapshot("pending"))
+}
+
+func TestLogSnapshotIdle(t *testing.T) {
+	assert := assert.New(t)
+
+	stop := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("so far\n"))
+		w.(http.Flusher).Flush()
+		<-stop
+	}))
+	defer server.Close()
+	defer close(stop)
+
+	var out bytes.Buffer
+	f := &logFollower{client: http.DefaultClient, liveURL: server.URL, out: &out, idle: 100 * time.Millisecond}
+	assert.NoError(f.snapshot("running"))
+	assert.Equal("so far\n", out.String())
+}
@@ -0,0 +1,121 @@
+package task
+
+import (
+	"fmt"
+	"os"
+
+	tcclient "github.com/taskcluster/taskcluster-client-go"
+	"github.com/taskcluster/taskcluster-client-go/queue"
+)
+
+// exceptionReasons are the reasons accepted by the queue for resolving a run
+// as exception.
+var exceptionReasons = map[string]bool{
+	"worker-shutdown":      true,
+	"malformed-payload":    true,
+	"resource-unavailable": true,
+	"internal-error":       true,
+	"superseded":           true,
+	"intermittent-task":    true,
+}
+
+func (t task) runResolve(credentials *tcclient.Credentials, args arguments) bool {
+	as := args["--as"].(string)
+	reason, _ := args["--reason"].(string)
+
+	switch {
+	case as != "completed" && as != "failed" && as != "exception":
+		fmt.Fprintf(os.Stderr, "error: cannot resolve as %s, use completed, failed or exception\n", as)
+		return false
+	case as == "exception" && !exceptionReasons[reason]:
+		fmt.Fprintf(os.Stderr, "error: invalid or missing --reason for exception: %q\n", reason)
+		return false
+	case as != "exception" && reason != "":
+		fmt.Fprintf(os.Stderr, "error: --reason can only be given with --as exception\n")
+		return false
+	}
+
+	return t.resolve(credentials, args["<taskId>"].(string), as, reason,
+		args["--worker-group"].(string), args["--worker-id"].(string))
+}
+
+// resolve resolves the latest run of the task as state, which is completed,
+// failed or exception with reason. Unscheduled tasks are scheduled and
+// pending runs claimed first, as the worker workerGroup/workerID, while
+// running runs are resolved with credentials, without a claim. Every step is
+// reported on stderr, as an audit trail.
+func (t task) resolve(
+	credentials *tcclient.Credentials, taskID, state, reason, workerGroup, workerID string,
+) bool {
+	q := t.queue(credentials)
+	by := "anonymous"
+	if credentials != nil {
+		by = credentials.ClientID
+	}
+
+	s, err := q.Status(taskID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not get the status of the task %s: %v\n", taskID, err)
+		return false
+	}
+	if len(s.Status.Runs) == 0 {
+		if s, err = q.ScheduleTask(taskID); err != nil {
+			fmt.Fprintf(os.Stderr, "error: could not schedule the task %s: %v\n", taskID, err)
+			return false
+		}
+		fmt.Fprintf(os.Stderr, "Scheduled the unscheduled task %s, by %s\n", taskID, by)
+		if len(s.Status.Runs) == 0 {
+			fmt.Fprintf(os.Stderr, "error: the task %s has no runs after scheduling it\n", taskID)
+			return false
+		}
+	}
+
+	runID := len(s.Status.Runs) - 1
+	run := s.Status.Runs[runID]
+	rq := q
+	switch run.State {
+	case "pending":
+		c, err := q.ClaimTask(taskID, fmt.Sprint(runID), &queue.TaskClaimRequest{
+			WorkerGroup: workerGroup,
+			WorkerID:    workerID,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: could not claim run %d of the task %s: %v\n", runID, taskID, err)
+			return false
+		}
+		fmt.Fprintf(os.Stderr, "Claimed run %d of task %s as worker %s/%s, by %s\n", runID, taskID, workerGroup, workerID, by)
+		rq = t.queue(&tcclient.Credentials{
+			ClientID:    c.Credentials.ClientID,
+			AccessToken: c.Credentials.AccessToken,
+			Certificate: c.Credentials.Certificate,
+		})
+	case "running":
+		fmt.Fprintf(os.Stderr, "Run %d of task %s is running on worker %s/%s\n", runID, taskID, run.WorkerGroup, run.WorkerID)
+	case state:
+		fmt.Fprintf(os.Stderr, "Run %d of task %s is already %s\n", runID, taskID, state)
+		fmt.Println(getRunStatusString(run.State, run.ReasonResolved))
+		return true
+	default:
+		fmt.Fprintf(os.Stderr, "error: run %d of task %s is already resolved as %s, rerun it first\n", runID, taskID, run.State)
+		return false
+	}
+
+	var res *queue.TaskStatusResponse
+	switch state {
+	case "completed":
+		res, err = rq.ReportCompleted(taskID, fmt.Sprint(runID))
+	case "failed":
+		res, err = rq.ReportFailed(taskID, fmt.Sprint(runID))
+	case "exception":
+		res, err = rq.ReportException(taskID, fmt.Sprint(runID), &queue.TaskExceptionRequest{Reason: reason})
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not resolve run %d of the task %s as %s: %v\n", runID, taskID, state, err)
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "Resolved run %d of task %s as %s, by %s\n", runID, taskID, state, by)
+
+	run = res.Status.Runs[runID]
+	fmt.Println(getRunStatusString(run.State, run.ReasonResolved))
+	return true
+}
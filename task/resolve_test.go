@@ -0,0 +1,114 @@
+package task
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+// resolveServer is a fake queue for a task with runs in the given states,
+// recording the requests made.
+type resolveServer struct {
+	server   *httptest.Server
+	states   []string
+	requests []string
+	claim    map[string]string
+	// unschedulable tasks have no runs after scheduling them
+	unschedulable bool
+}
+
+func newResolveServer(states ...string) *resolveServer {
+	r := &resolveServer{states: states}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.requests = append(r.requests, req.Method+" "+req.URL.Path)
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/schedule"):
+			if !r.unschedulable {
+				r.states = append(r.states, "pending")
+			}
+		case strings.HasSuffix(req.URL.Path, "/claim"):
+			json.NewDecoder(req.Body).Decode(&r.claim)
+			r.states[len(r.states)-1] = "running"
+		case req.Method == "POST":
+			state := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+			r.states[len(r.states)-1] = state
+		}
+		runs := []map[string]interface{}{}
+		for i, state := range r.states {
+			runs = append(runs, map[string]interface{}{"runId": i, "state": state})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":      map[string]interface{}{"runs": runs},
+			"runId":       len(r.states) - 1,
+			"credentials": map[string]string{"clientId": "worker"},
+		})
+	}))
+	return r
+}
+
+func TestResolve(t *testing.T) {
+	assert := assert.New(t)
+	resolve := func(r *resolveServer, args arguments) bool {
+		args["<taskId>"] = "abc"
+		args["--worker-group"] = "group"
+		args["--worker-id"] = "id"
+		return task{queueBaseURL: r.server.URL}.runResolve(nil, args)
+	}
+
+	// Unscheduled tasks are scheduled and claimed
+	r := newResolveServer()
+	defer r.server.Close()
+	assert.True(resolve(r, arguments{"--as": "failed"}))
+	assert.Equal([]string{
+		"GET /task/abc/status",
+		"POST /task/abc/schedule",
+		"POST /task/abc/runs/0/claim",
+		"POST /task/abc/runs/0/failed",
+	}, r.requests)
+	assert.Equal(map[string]string{"workerGroup": "group", "workerId": "id"}, r.claim)
+	assert.Equal([]string{"failed"}, r.states)
+
+	// Running runs are resolved without a claim
+	r = newResolveServer("exception", "running")
+	defer r.server.Close()
+	assert.True(resolve(r, arguments{"--as": "exception", "--reason": "superseded"}))
+	assert.Equal([]string{"GET /task/abc/status", "POST /task/abc/runs/1/exception"}, r.requests)
+
+	// Resolved runs are left alone
+	r = newResolveServer("completed")
+	defer r.server.Close()
+	assert.True(resolve(r, arguments{"--as": "completed"}))
+	assert.False(resolve(r, arguments{"--as": "failed"}))
+	assert.Equal([]string{"GET /task/abc/status", "GET /task/abc/status"}, r.requests)
+
+	// Invalid arguments
+	assert.False(resolve(r, arguments{"--as": "done"}))
+	assert.False(resolve(r, arguments{"--as": "exception"}))
+	assert.False(resolve(r, arguments{"--as": "exception", "--reason": "bored"}))
+	assert.False(resolve(r, arguments{"--as": "failed", "--reason": "superseded"}))
+	assert.Len(r.requests, 2)
+}
+
+func TestResolveEdgeCases(t *testing.T) {
+	assert := assert.New(t)
+
+	// Completing a task claims it as the given worker
+	r := newResolveServer("pending")
+	defer r.server.Close()
+	assert.True(task{queueBaseURL: r.server.URL}.runComplete(nil, arguments{
+		"<taskId>": "abc", "--worker-group": "group", "--worker-id": "id",
+	}))
+	assert.Equal(map[string]string{"workerGroup": "group", "workerId": "id"}, r.claim)
+	assert.Equal([]string{"completed"}, r.states)
+
+	// Tasks without runs after scheduling them can't be resolved
+	r = newResolveServer()
+	r.unschedulable = true
+	defer r.server.Close()
+	assert.False(task{queueBaseURL: r.server.URL}.resolve(nil, "abc", "completed", "", "group", "id"))
+	assert.Equal([]string{"GET /task/abc/status", "POST /task/abc/schedule"}, r.requests)
+}
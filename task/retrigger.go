@@ -0,0 +1,215 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/taskcluster/taskcluster-cli/apis"
+	"github.com/taskcluster/taskcluster-cli/client"
+	"github.com/taskcluster/taskcluster-cli/slugid"
+	tcclient "github.com/taskcluster/taskcluster-client-go"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func (t task) runRetrigger(credentials *tcclient.Credentials, args arguments) bool {
+	q := t.queue(credentials)
+	taskID := args["<taskId>"].(string)
+
+	definition, err := q.Task(taskID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not get the task %s: %v\n", taskID, err)
+		return false
+	}
+	def := toDocument(definition).(map[string]interface{})
+
+	if err := shiftTimes(def, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not update the times of task %s: %v\n", taskID, err)
+		return false
+	}
+	if args["--clear-deps"] == true {
+		delete(def, "dependencies")
+	}
+	env, err := parseParams(args["--env"].([]string))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return false
+	}
+	if err := setEnv(def, env); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return false
+	}
+	if assignments := args["--set"].([]string); len(assignments) > 0 {
+		doc, err := apis.BuildInput("queue", "createTask", def, assignments)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return false
+		}
+		def = doc.(map[string]interface{})
+	}
+	if args["--edit"] == true {
+		if def, err = editDefinition(def); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return false
+		}
+	}
+
+	newTaskID := slugid.Nice()
+	if err := submitTask(q, newTaskID, def); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return false
+	}
+
+	fmt.Println(newTaskID)
+	fmt.Println(client.InspectorURL(t.rootURL, newTaskID))
+	return true
+}
+
+// shiftTimes moves the deadline and expires times of the task definition def,
+// and the expires times of the artifacts in its payload, by the time since it
+// was created, and sets created to now.
+func shiftTimes(def map[string]interface{}, now time.Time) error {
+	created, err := parseTime(def["created"])
+	if err != nil {
+		return fmt.Errorf("invalid created: %v", err)
+	}
+	offset := now.Sub(created)
+	def["created"] = tcclient.Time(now)
+
+	shift := func(m map[string]interface{}, key string) error {
+		if _, ok := m[key]; !ok {
+			return nil
+		}
+		t, err := parseTime(m[key])
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", key, err)
+		}
+		m[key] = tcclient.Time(t.Add(offset))
+		return nil
+	}
+	if err := shift(def, "deadline"); err != nil {
+		return err
+	}
+	if err := shift(def, "expires"); err != nil {
+		return err
+	}
+
+	// Artifacts are a map from name to artifact for docker-worker, and a list
+	// of artifacts for generic-worker
+	payload, _ := def["payload"].(map[string]interface{})
+	var artifacts []interface{}
+	switch a := payload["artifacts"].(type) {
+	case map[string]interface{}:
+		for _, artifact := range a {
+			artifacts = append(artifacts, artifact)
+		}
+	case []interface{}:
+		artifacts = a
+	}
+	for _, artifact := range artifacts {
+		if m, ok := artifact.(map[string]interface{}); ok {
+			if err := shift(m, "expires"); err != nil {
+				return fmt.Errorf("artifact %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseTime parses a time in a task definition.
+func parseTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case tcclient.Time:
+		return time.Time(v), nil
+	case string:
+		return time.Parse(time.RFC3339, v)
+	}
+	return time.Time{}, fmt.Errorf("expected a time, got %v", value)
+}
+
+// setEnv sets the environment variables in the payload of the task definition
+// def.
+func setEnv(def map[string]interface{}, env map[string]string) error {
+	if len(env) == 0 {
+		return nil
+	}
+	payload, ok := def["payload"].(map[string]interface{})
+	if !ok {
+		return errors.New("cannot set environment variables, the payload isn't an object")
+	}
+	vars, ok := payload["env"].(map[string]interface{})
+	if !ok {
+		if payload["env"] != nil {
+			return errors.New("cannot set environment variables, payload.env isn't an object")
+		}
+		vars = make(map[string]interface{})
+		payload["env"] = vars
+	}
+	for k, v := range env {
+		vars[k] = v
+	}
+	return nil
+}
+
+// editDefinition opens the task definition def as YAML in $EDITOR, and returns
+// the edited definition.
+func editDefinition(def map[string]interface{}) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(toDocument(def))
+	if err != nil {
+		panic(fmt.Sprintf("internal error: could not marshal task definition: %v", err))
+	}
+	dir, err := ioutil.TempDir("", "taskcluster-retrigger")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "task.yml")
+	if err := ioutil.WriteFile(file, data, 0600); err != nil {
+		return nil, err
+	}
+
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+	cmd := exec.Command(editor[0], append(editor[1:], file)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("editor %s failed: %v", editor[0], err)
+	}
+
+	if data, err = ioutil.ReadFile(file); err != nil {
+		return nil, err
+	}
+	value, err := apis.ParseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	edited, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("the edited task definition must be an object")
+	}
+	return edited, nil
+}
+
+// toDocument converts value to the generic values of a decoded JSON document,
+// e.g. times to strings.
+func toDocument(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(fmt.Sprintf("internal error: could not marshal %T: %v", value, err))
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		panic(fmt.Sprintf("internal error: could not unmarshal %T: %v", value, err))
+	}
+	return doc
+}
@@ -0,0 +1,112 @@
+package task
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	tcclient "github.com/taskcluster/taskcluster-client-go"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+const retriggerTask = `{
+  "provisionerId": "aws-provisioner-v1",
+  "workerType": "tutorial",
+  "taskGroupId": "fN1SbArXTPSVFNUvaOlinQ",
+  "dependencies": ["Ti1Fgq6CTZaHqY5WoxpBsQ"],
+  "created": "2017-03-01T12:00:00.000Z",
+  "deadline": "2017-03-02T12:00:00.000Z",
+  "expires": "2018-03-01T12:00:00.000Z",
+  "payload": {
+    "command": ["echo", "hello"],
+    "maxRunTime": 600,
+    "artifacts": {"public/build": {"type": "directory", "path": "/build", "expires": "2017-03-31T12:00:00.000Z"}}
+  },
+  "metadata": {"name": "hello", "description": "Say hello", "owner": "nobody@example.com", "source": "https://example.com"}
+}`
+
+func TestShiftTimes(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2017, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	var def map[string]interface{}
+	assert.NoError(json.Unmarshal([]byte(retriggerTask), &def))
+	assert.NoError(shiftTimes(def, now))
+	assert.Equal(tcclient.Time(now), def["created"])
+	assert.Equal(tcclient.Time(now.AddDate(0, 0, 1)), def["deadline"])
+	assert.Equal(tcclient.Time(now.AddDate(1, 0, 0)), def["expires"])
+	artifact := def["payload"].(map[string]interface{})["artifacts"].(map[string]interface{})["public/build"]
+	assert.Equal(tcclient.Time(now.AddDate(0, 0, 30)), artifact.(map[string]interface{})["expires"])
+
+	// generic-worker artifacts are a list
+	def = map[string]interface{}{
+		"created": "2017-03-01T12:00:00.000Z",
+		"payload": map[string]interface{}{
+			"artifacts": []interface{}{map[string]interface{}{"expires": "2017-03-01T13:00:00.000Z"}},
+		},
+	}
+	assert.NoError(shiftTimes(def, now))
+	artifact = def["payload"].(map[string]interface{})["artifacts"].([]interface{})[0]
+	assert.Equal(tcclient.Time(now.Add(time.Hour)), artifact.(map[string]interface{})["expires"])
+
+	assert.Error(shiftTimes(map[string]interface{}{"created": "yesterday"}, now))
+}
+
+func TestSetEnv(t *testing.T) {
+	assert := assert.New(t)
+
+	def := map[string]interface{}{"payload": map[string]interface{}{}}
+	assert.NoError(setEnv(def, map[string]string{"DEBUG": "1"}))
+	assert.NoError(setEnv(def, map[string]string{"LOG": "trace"}))
+	assert.Equal(map[string]interface{}{"DEBUG": "1", "LOG": "trace"}, def["payload"].(map[string]interface{})["env"])
+
+	assert.Error(setEnv(map[string]interface{}{"payload": map[string]interface{}{"env": "x"}}, map[string]string{"A": "b"}))
+}
+
+func TestRetrigger(t *testing.T) {
+	assert := assert.New(t)
+
+	var created map[string]interface{}
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			w.Write([]byte(retriggerTask))
+			return
+		}
+		path = r.Method + " " + r.URL.Path
+		json.NewDecoder(r.Body).Decode(&created)
+		w.Write([]byte(`{"status": {}}`))
+	}))
+	defer server.Close()
+	tk := task{queueBaseURL: server.URL}
+
+	editor := os.Getenv("EDITOR")
+	defer os.Setenv("EDITOR", editor)
+	os.Setenv("EDITOR", "sed -i s/hello$/bye/")
+
+	assert.True(tk.runRetrigger(nil, arguments{
+		"<taskId>":     "abc",
+		"--set":        []string{"payload.maxRunTime=3600", "retries=2"},
+		"--env":        []string{"DEBUG=1"},
+		"--clear-deps": true,
+		"--edit":       true,
+	}))
+	assert.True(strings.HasPrefix(path, "PUT /task/"))
+	assert.NotEqual("PUT /task/abc", path)
+	assert.Equal("fN1SbArXTPSVFNUvaOlinQ", created["taskGroupId"])
+	assert.Equal(float64(2), created["retries"])
+	assert.Nil(created["dependencies"])
+	payload := created["payload"].(map[string]interface{})
+	assert.Equal([]interface{}{"echo", "bye"}, payload["command"])
+	assert.Equal(float64(3600), payload["maxRunTime"])
+	assert.Equal(map[string]interface{}{"DEBUG": "1"}, payload["env"])
+
+	deadline, err := time.Parse(time.RFC3339, created["deadline"].(string))
+	assert.NoError(err)
+	assert.WithinDuration(time.Now().AddDate(0, 0, 1), deadline, time.Minute)
+}
@@ -0,0 +1,218 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	tcclient "github.com/taskcluster/taskcluster-client-go"
+)
+
+// dockerPayload is the part of a docker-worker payload needed to run the task
+// locally.
+type dockerPayload struct {
+	Image     json.RawMessage   `json:"image"`
+	Command   []string          `json:"command"`
+	Env       map[string]string `json:"env"`
+	Cache     map[string]string `json:"cache"`
+	Artifacts map[string]struct {
+		Type string `json:"type"`
+		Path string `json:"path"`
+	} `json:"artifacts"`
+	Capabilities struct {
+		Privileged bool `json:"privileged"`
+	} `json:"capabilities"`
+}
+
+func (t task) runRunLocal(credentials *tcclient.Credentials, args arguments) bool {
+	q := t.queue(credentials)
+	taskID := args["<taskId>"].(string)
+
+	def, err := q.Task(taskID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not get the task %s: %v\n", taskID, err)
+		return false
+	}
+	d, err := newDockerRun(taskID, def.Payload, args["--dest"].(string))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: cannot run task %s locally: %v\n", taskID, err)
+		return false
+	}
+
+	if args["--print"] == true {
+		for _, command := range d.commands() {
+			fmt.Println(shellQuote(command))
+		}
+		return true
+	}
+
+	// Remove the container left by a previous run that didn't get to remove
+	// it, there is usually none
+	exec.Command(d.clean[0], d.clean[1:]...).Run()
+
+	failed := runCommand(d.run)
+	if _, ok := failed.(*exec.ExitError); failed != nil && !ok {
+		fmt.Fprintf(os.Stderr, "error: could not run docker: %v\n", failed)
+		return false
+	}
+
+	// Copy artifacts and remove the container, even if the task failed
+	for _, cp := range d.copies {
+		if err := os.MkdirAll(filepath.Dir(cp[len(cp)-1]), 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			continue
+		}
+		if err := runCommand(cp); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s failed: %v\n", shellQuote(cp), err)
+		}
+	}
+	if err := runCommand(d.remove); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s failed: %v\n", shellQuote(d.remove), err)
+	}
+
+	if failed != nil {
+		fmt.Fprintf(os.Stderr, "Task %s failed: %v\n", taskID, failed)
+		return t.fail(exitFailed)
+	}
+	return true
+}
+
+// runCommand runs command with the standard streams of the process.
+func runCommand(command []string) error {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// dockerRun are the docker commands to run a docker-worker task locally.
+type dockerRun struct {
+	// clean is the 'docker rm -f' removing a container left by a previous run
+	clean []string
+	// run is the 'docker run' running the task
+	run []string
+	// copies are the 'docker cp' copying artifacts out of the container
+	copies [][]string
+	// remove is the 'docker rm' removing the container
+	remove []string
+}
+
+func (d *dockerRun) commands() [][]string {
+	commands := append([][]string{d.clean, d.run}, d.copies...)
+	return append(commands, d.remove)
+}
+
+// newDockerRun returns the commands to run the docker-worker payload of the
+// task locally, copying artifacts to dest. Caches are mounted from docker
+// volumes named after the cache, so they persist between runs. Directory
+// artifacts are copied into the destination directory, so they aren't nested
+// in it if it exists from a previous run.
+func newDockerRun(taskID string, data json.RawMessage, dest string) (*dockerRun, error) {
+	var payload dockerPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %v", err)
+	}
+	image, err := dockerImage(payload.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	container := "taskcluster-" + taskID
+	run := []string{"docker", "run", "--name", container}
+	if payload.Capabilities.Privileged {
+		run = append(run, "--privileged")
+	}
+
+	env := map[string]string{"TASK_ID": taskID, "RUN_ID": "0"}
+	for k, v := range payload.Env {
+		env[k] = v
+	}
+	for _, k := range sortedKeys(env) {
+		run = append(run, "-e", k+"="+env[k])
+	}
+	for _, name := range sortedKeys(payload.Cache) {
+		run = append(run, "-v", "taskcluster-cache-"+name+":"+payload.Cache[name])
+	}
+	run = append(run, image)
+	run = append(run, payload.Command...)
+
+	d := &dockerRun{
+		clean:  []string{"docker", "rm", "-f", container},
+		run:    run,
+		remove: []string{"docker", "rm", container},
+	}
+	names := make([]string, 0, len(payload.Artifacts))
+	for name := range payload.Artifacts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p, err := artifactPath(dest, name)
+		if err != nil {
+			return nil, err
+		}
+		src := container + ":" + payload.Artifacts[name].Path
+		if payload.Artifacts[name].Type == "directory" {
+			src = strings.TrimSuffix(src, "/") + "/."
+		}
+		d.copies = append(d.copies, []string{"docker", "cp", src, p})
+	}
+	return d, nil
+}
+
+// dockerImage returns the name of the docker image of a docker-worker
+// payload, given as a name or as an object of type docker-image. Images from
+// artifacts have to be loaded first, so they aren't supported.
+func dockerImage(data json.RawMessage) (string, error) {
+	if len(data) == 0 {
+		return "", errors.New("the payload has no image, only docker-worker tasks are supported")
+	}
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		return name, nil
+	}
+	var image struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &image); err != nil {
+		return "", fmt.Errorf("invalid image: %v", err)
+	}
+	if image.Type != "docker-image" {
+		return "", fmt.Errorf("images of type %s are not supported, download and docker load the image", image.Type)
+	}
+	return image.Name, nil
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// safeShellWord matches arguments that don't need quoting in a shell.
+var safeShellWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote returns command as a line that can be pasted into a shell.
+func shellQuote(command []string) string {
+	words := make([]string, len(command))
+	for i, arg := range command {
+		if safeShellWord.MatchString(arg) {
+			words[i] = arg
+		} else {
+			words[i] = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+		}
+	}
+	return strings.Join(words, " ")
+}
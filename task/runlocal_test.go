@@ -0,0 +1,55 @@
+package task
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestDockerRun(t *testing.T) {
+	assert := assert.New(t)
+
+	d, err := newDockerRun("abc", json.RawMessage(`{
+		"image": "ubuntu:16.04",
+		"command": ["/bin/bash", "-c", "echo 'hello world' > /build/out.txt"],
+		"env": {"DEBUG": "1", "TASK_ID": "overridden"},
+		"cache": {"level-3-checkouts": "/checkouts"},
+		"capabilities": {"privileged": true},
+		"artifacts": {
+			"public/build": {"type": "directory", "path": "/build"},
+			"public/logs/test.log": {"type": "file", "path": "/test.log"}
+		}
+	}`), "out")
+	assert.NoError(err)
+	assert.Equal([][]string{
+		{"docker", "rm", "-f", "taskcluster-abc"},
+		{
+			"docker", "run", "--name", "taskcluster-abc", "--privileged",
+			"-e", "DEBUG=1", "-e", "RUN_ID=0", "-e", "TASK_ID=overridden",
+			"-v", "taskcluster-cache-level-3-checkouts:/checkouts",
+			"ubuntu:16.04", "/bin/bash", "-c", "echo 'hello world' > /build/out.txt",
+		},
+		{"docker", "cp", "taskcluster-abc:/build/.", filepath.Join("out", "public", "build")},
+		{"docker", "cp", "taskcluster-abc:/test.log", filepath.Join("out", "public", "logs", "test.log")},
+		{"docker", "rm", "taskcluster-abc"},
+	}, d.commands())
+	assert.Equal(
+		`docker run --name taskcluster-abc --privileged -e DEBUG=1 -e RUN_ID=0 -e TASK_ID=overridden `+
+			`-v taskcluster-cache-level-3-checkouts:/checkouts ubuntu:16.04 /bin/bash -c 'echo '\''hello world'\'' > /build/out.txt'`,
+		shellQuote(d.run),
+	)
+
+	d, err = newDockerRun("abc", json.RawMessage(`{"image": {"type": "docker-image", "name": "node:8"}, "command": ["npm", "test"]}`), ".")
+	assert.NoError(err)
+	assert.Equal([]string{"docker", "run", "--name", "taskcluster-abc", "-e", "RUN_ID=0", "-e", "TASK_ID=abc", "node:8", "npm", "test"}, d.run)
+	assert.Empty(d.copies)
+
+	_, err = newDockerRun("abc", json.RawMessage(`{"image": {"type": "task-image", "taskId": "def", "path": "public/image.tar"}}`), ".")
+	assert.Error(err)
+	_, err = newDockerRun("abc", json.RawMessage(`{"command": [["echo", "hello"]]}`), ".")
+	assert.Error(err)
+	_, err = newDockerRun("abc", json.RawMessage(`{"image": "ubuntu", "artifacts": {"../escape": {"path": "/x"}}}`), "out")
+	assert.Error(err)
+}
@@ -1,9 +1,12 @@
 package task
 
 import (
+	"github.com/taskcluster/taskcluster-cli/client"
+	"github.com/taskcluster/taskcluster-cli/config"
 	"github.com/taskcluster/taskcluster-cli/extpoints"
 
 	tcclient "github.com/taskcluster/taskcluster-client-go"
+	"github.com/taskcluster/taskcluster-client-go/queue"
 )
 
 func init() {
@@ -11,6 +14,12 @@ func init() {
 }
 
 type task struct {
+	// queueBaseURL is the base URL of the queue, resolved by Execute
+	queueBaseURL string
+	// rootURL is the root URL of the deployment, set by Execute
+	rootURL string
+	// fail fails the command with an exit code, set by Execute
+	fail func(code int) bool
 }
 
 func (task) ConfigOptions() map[string]extpoints.ConfigOption {
@@ -21,26 +30,119 @@ func (task) Summary() string {
 	return "Task related actions."
 }
 
+func (task) UsesCredentials() bool {
+	return true
+}
+
 func (task) Usage() string {
 	return `Task related actions.
 
 Usage:
-  taskcluster task status [--all-runs | --run ID] [--] <taskId>
+  taskcluster task status [--all-runs | --run ID | --query <expr>] [--] <taskId>
   taskcluster task name [--] <taskId>
   taskcluster task group [--] <taskId>
   taskcluster task artifacts [--run ID] [--] <taskId>
+  taskcluster task wait [--timeout <duration>] [--interval <duration>] [--] <taskIds>...
+  taskcluster task log [--run ID] [--follow] [--no-color] [--timestamps] [--] <taskId>
+  taskcluster task download [--run ID] [--dest <dir>] [--glob <pattern>] [--jobs N] [--] <taskId> [<artifactName>...]
+  taskcluster task upload --name <name> [--expires <duration>] [--content-type <type>] [--] <taskId> <runId> <file>
+  taskcluster task upload --reference <url> --name <name> [--expires <duration>] [--content-type <type>] [--] <taskId> <runId>
+  taskcluster task upload --error <reason> --message <message> --name <name> [--expires <duration>] [--] <taskId> <runId>
+  taskcluster task artifact-url [--run ID] [--expires <duration>] [--] <taskId> <name>
   taskcluster task cancel [--] <taskId>
   taskcluster task rerun [--] <taskId>
-  taskcluster task complete [--] <taskId>
+  taskcluster task complete [--worker-group <name>] [--worker-id <id>] [--] <taskId>
+  taskcluster task create [--param <key=value>]... [--] <file>
+  taskcluster task retrigger [--edit] [--set <path=value>]... [--env <key=value>]... [--clear-deps] [--] <taskId>
+  taskcluster task loaner [--timeout <duration>] [--interval <duration>] [--] <taskId>
+  taskcluster task run-local [--print] [--dest <dir>] [--] <taskId>
+  taskcluster task resolve --as <state> [--reason <reason>] [--worker-group <name>] [--worker-id <id>] [--] <taskId>
+  taskcluster task inspect [--format <format>] [--] <taskId>
 
 Options:
-  --all-runs  Use all runs instead of only the latest
-  --run ID    Use a specific run ID. By default, the latest run is selected
+  --all-runs             Use all runs instead of only the latest
+  --run ID               Use a specific run ID. By default, the latest run is selected
+  --query <expr>         Select values from the task status, e.g. runs[-1].workerId
+  --expires <duration>   Time until a signed URL or uploaded artifact expires. By
+                         default signed URLs expire in 1 hour and artifacts with the task
+  --timeout <duration>   Stop waiting after <duration>, e.g. '2 hours'
+  --interval <duration>  Time between the first polls of the queue, increased while
+                         nothing changes [default: 5 seconds]
+  --follow               Stream the live log until the run is resolved
+  --no-color             Remove ANSI color codes from the log
+  --timestamps           Prefix log lines with the time they were received
+  --dest <dir>           Directory to download or copy artifacts to [default: .]
+  --glob <pattern>       Only download artifacts with names matching <pattern>, e.g. 'public/logs/*'
+  --jobs N               Number of artifacts to download concurrently [default: 4]
+  --name <name>          Name of the artifact to upload, e.g. public/logs/debug.log
+  --content-type <type>  Content type of the artifact, inferred from the file if not given
+  --reference <url>      Upload a reference artifact, redirecting to <url>
+  --error <reason>       Upload an error artifact, with reason file-missing-on-worker,
+                         invalid-resource-on-worker or too-large-file-on-worker
+  --message <message>    Message explaining the error artifact
+  --param <key=value>    Set a parameter of the task template
+  --edit                 Edit the task definition in $EDITOR before creating the task
+  --set <path=value>     Set a field of the task definition, e.g. payload.maxRunTime=3600
+  --env <key=value>      Set an environment variable in the payload
+  --clear-deps           Remove the dependencies of the task
+  --print                Print the docker commands instead of running them
+  --as <state>           State to resolve the task as, completed, failed or exception
+  --reason <reason>      Reason for resolving as exception, e.g. malformed-payload,
+                         superseded or internal-error
+  --worker-group <name>  Worker group to claim pending runs as [default: taskcluster-cli]
+  --worker-id <id>       Worker id to claim pending runs as [default: taskcluster-cli]
+  --format <format>      Print the inspection as json or yaml, instead of a summary
+
+Waiting prints the tasks that change state, until all tasks are resolved. It
+exits with 0 if all tasks completed, 2 if any task failed, 3 if any task was
+resolved as exception and 4 on timeout.
+
+Logs are read from the live log while the run is running, reconnecting if the
+connection drops, and from the backing log once the run is resolved.
+
+Downloads are resumed if interrupted, artifacts with gzip content-encoding are
+decompressed and sizes and checksums are verified when the artifact has them.
+
+Uploads of files create s3 artifacts, the file is uploaded to the URL returned
+by the queue.
+
+Artifact URLs for private artifacts are signed with the configured credentials,
+so they can be shared without sharing the credentials.
+
+Tasks are created from YAML templates, where '${name}' in strings is replaced
+by the parameter, {$eval: name} by the parameter parsed as YAML and
+{$fromNow: '2 hours'} by the time relative to now. The taskId parameter is a
+new slug, unless given, and created defaults to now. The task definition is
+validated before it is created.
+
+Retriggering creates a copy of a task with a new taskId, unlike rerun, which
+adds a run to the same task. The deadline, expiry and artifact expiries keep
+their distance from the time the task is created.
+
+A loaner is a copy of a task with the interactive feature enabled, a
+maxRunTime of at least 3 hours and without dependencies and routes. Once it is
+running, the URLs of its interactive shell and display are printed, signed
+with the configured credentials.
+
+Running a docker-worker task locally runs its image and command with docker,
+with caches in docker volumes named taskcluster-cache-<name>. Artifacts are
+copied from the container once the command has finished, and it exits with 2
+if the command failed.
+
+Resolving a task schedules it if it is unscheduled and claims its latest run if
+it is pending, before resolving the run, while running runs are resolved with
+the configured credentials. Completing a task resolves it as completed.
+
+Inspecting a task prints its definition and status, the states of its
+dependencies and the number of artifacts of each run.
 `
 }
 
 func (t task) Execute(context extpoints.Context) bool {
 	args := context.Arguments
+	t.queueBaseURL = queueBaseURL(context)
+	t.rootURL = context.RootURL
+	t.fail = context.Fail
 
 	if args["status"].(bool) {
 		return executeSubCommand(context, t.runStatus)
@@ -54,6 +156,21 @@ func (t task) Execute(context extpoints.Context) bool {
 	if args["artifacts"].(bool) {
 		return executeSubCommand(context, t.runArtifacts)
 	}
+	if args["wait"].(bool) {
+		return executeSubCommand(context, t.runWait)
+	}
+	if args["log"].(bool) {
+		return executeSubCommand(context, t.runLog)
+	}
+	if args["download"].(bool) {
+		return executeSubCommand(context, t.runDownload)
+	}
+	if args["upload"].(bool) {
+		return executeSubCommand(context, t.runUpload)
+	}
+	if args["artifact-url"].(bool) {
+		return executeSubCommand(context, t.runArtifactURL)
+	}
 	if args["cancel"].(bool) {
 		return executeSubCommand(context, t.runCancel)
 	}
@@ -63,6 +180,24 @@ func (t task) Execute(context extpoints.Context) bool {
 	if args["complete"].(bool) {
 		return executeSubCommand(context, t.runComplete)
 	}
+	if args["create"].(bool) {
+		return executeSubCommand(context, t.runCreate)
+	}
+	if args["retrigger"].(bool) {
+		return executeSubCommand(context, t.runRetrigger)
+	}
+	if args["loaner"].(bool) {
+		return executeSubCommand(context, t.runLoaner)
+	}
+	if args["run-local"].(bool) {
+		return executeSubCommand(context, t.runRunLocal)
+	}
+	if args["resolve"].(bool) {
+		return executeSubCommand(context, t.runResolve)
+	}
+	if args["inspect"].(bool) {
+		return executeSubCommand(context, t.runInspect)
+	}
 
 	return false
 }
@@ -76,3 +211,19 @@ func executeSubCommand(context extpoints.Context, subCommand SubCommand) bool {
 
 	return subCommand(c, context.Arguments)
 }
+
+// queueBaseURL returns the base URL of the queue, which is the base URL
+// configured for the queue command, or derived from the root URL.
+func queueBaseURL(context extpoints.Context) string {
+	if baseURL, ok := config.Configuration["queue"]["baseUrl"].(string); ok && baseURL != "" {
+		return baseURL
+	}
+	return client.ServiceURL(context.RootURL, "queue", "v1")
+}
+
+// queue returns a queue client using credentials and the resolved base URL.
+func (t task) queue(credentials *tcclient.Credentials) *queue.Queue {
+	q := queue.New(credentials)
+	q.BaseURL = t.queueBaseURL
+	return q
+}
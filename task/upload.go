@@ -0,0 +1,184 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	fromNow "github.com/taskcluster/taskcluster-cli/from-now"
+	"github.com/taskcluster/taskcluster-cli/retry"
+	tcclient "github.com/taskcluster/taskcluster-client-go"
+	"github.com/taskcluster/taskcluster-client-go/queue"
+)
+
+// errorReasons are the reasons accepted by the queue for error artifacts.
+var errorReasons = map[string]bool{
+	"file-missing-on-worker":     true,
+	"invalid-resource-on-worker": true,
+	"too-large-file-on-worker":   true,
+}
+
+func (t task) runUpload(credentials *tcclient.Credentials, args arguments) bool {
+	q := t.queue(credentials)
+	taskID := args["<taskId>"].(string)
+	runID := args["<runId>"].(string)
+	name := args["--name"].(string)
+	contentType, _ := args["--content-type"].(string)
+
+	// Artifacts expire with the task, unless given
+	var expires time.Time
+	if s, ok := args["--expires"].(string); ok {
+		var err error
+		if expires, err = fromNow.Add(time.Now(), s); err != nil {
+			fmt.Fprintf(os.Stderr, "error: invalid duration given for --expires: %s\n", s)
+			return false
+		}
+	} else {
+		def, err := q.Task(taskID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: could not get the task %s: %v\n", taskID, err)
+			return false
+		}
+		expires = time.Time(def.Expires)
+	}
+
+	request := map[string]interface{}{
+		"expires": tcclient.Time(expires),
+	}
+	var file string
+	switch {
+	case args["--reference"] != nil:
+		request["storageType"] = "reference"
+		request["url"] = args["--reference"].(string)
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		request["contentType"] = contentType
+	case args["--error"] != nil:
+		reason := args["--error"].(string)
+		if !errorReasons[reason] {
+			fmt.Fprintf(os.Stderr, "error: invalid reason for error artifact: %s\n", reason)
+			return false
+		}
+		request["storageType"] = "error"
+		request["reason"] = reason
+		request["message"] = args["--message"].(string)
+	default:
+		file = args["<file>"].(string)
+		if contentType == "" {
+			var err error
+			if contentType, err = detectContentType(file); err != nil {
+				fmt.Fprintf(os.Stderr, "error: could not read %s: %v\n", file, err)
+				return false
+			}
+		}
+		request["storageType"] = "s3"
+		request["contentType"] = contentType
+	}
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		panic(fmt.Sprintf("internal error: could not marshal artifact request: %v", err))
+	}
+	payload := queue.PostArtifactRequest(data)
+	res, err := q.CreateArtifact(taskID, runID, name, &payload)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not create artifact %s for task %s run %s: %v\n", name, taskID, runID, err)
+		return false
+	}
+
+	if file != "" {
+		var s3 struct {
+			PutURL      string `json:"putUrl"`
+			ContentType string `json:"contentType"`
+		}
+		if err := json.Unmarshal([]byte(*res), &s3); err != nil || s3.PutURL == "" {
+			fmt.Fprintf(os.Stderr, "error: unexpected response creating artifact %s: %s\n", name, string(*res))
+			return false
+		}
+		if s3.ContentType == "" {
+			s3.ContentType = contentType
+		}
+		if err := putFile(s3.PutURL, file, s3.ContentType, retry.DefaultOptions); err != nil {
+			fmt.Fprintf(os.Stderr, "error: could not upload %s: %v\n", file, err)
+			return false
+		}
+	}
+
+	fmt.Printf("Created artifact %s for task %s run %s\n", name, taskID, runID)
+	return true
+}
+
+// detectContentType returns the content type of file from its extension, or
+// from its first bytes if the extension is unknown.
+func detectContentType(file string) (string, error) {
+	if contentType := mime.TypeByExtension(filepath.Ext(file)); contentType != "" {
+		return contentType, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// putFile uploads file to the signed url with contentType, streaming it from
+// disk and retrying on connection errors and 5xx responses.
+func putFile(url, file, contentType string, opts retry.Options) error {
+	for attempt := 0; ; attempt++ {
+		err := putFileOnce(url, file, contentType)
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(permanentError); ok || attempt >= opts.MaxRetries {
+			return err
+		}
+		time.Sleep(opts.Delay(attempt + 1))
+	}
+}
+
+func putFileOnce(url, file, contentType string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return permanentError{err}
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return permanentError{err}
+	}
+
+	req, err := http.NewRequest("PUT", url, f)
+	if err != nil {
+		return permanentError{err}
+	}
+	req.ContentLength = info.Size()
+	if req.ContentLength == 0 {
+		// Avoid a chunked request for empty files, S3 requires a length
+		req.Body = http.NoBody
+	}
+	req.Header.Set("Content-Type", contentType)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 == 2 {
+		return nil
+	}
+	if res.StatusCode/100 == 5 {
+		return responseError(res)
+	}
+	return permanentError{responseError(res)}
+}
@@ -0,0 +1,142 @@
+package task
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+// fakeQueue is a stand-in for the queue and S3, recording created artifacts
+// and uploaded files.
+type fakeQueue struct {
+	mu        sync.Mutex
+	server    *httptest.Server
+	artifacts map[string]map[string]interface{}
+	uploads   map[string][]byte
+	types     map[string]string
+}
+
+func newFakeQueue() *fakeQueue {
+	f := &fakeQueue{
+		artifacts: make(map[string]map[string]interface{}),
+		uploads:   make(map[string][]byte),
+		types:     make(map[string]string),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+func (f *fakeQueue) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/task/abc":
+		w.Write([]byte(`{"expires": "2030-01-01T00:00:00.000Z"}`))
+	case r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/task/abc/runs/0/artifacts/"):
+		name := r.URL.Path[len("/task/abc/runs/0/artifacts/"):]
+		var request map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&request)
+		f.artifacts[name] = request
+		response := map[string]interface{}{"storageType": request["storageType"]}
+		if request["storageType"] == "s3" {
+			response["putUrl"] = f.server.URL + "/s3/" + name
+			response["contentType"] = request["contentType"]
+		}
+		json.NewEncoder(w).Encode(response)
+	case r.Method == "PUT" && strings.HasPrefix(r.URL.Path, "/s3/"):
+		name := r.URL.Path[len("/s3/"):]
+		f.uploads[name], _ = ioutil.ReadAll(r.Body)
+		f.types[name] = r.Header.Get("Content-Type")
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "Not found"}`))
+	}
+}
+
+func TestUpload(t *testing.T) {
+	assert := assert.New(t)
+
+	f := newFakeQueue()
+	defer f.server.Close()
+	tk := task{queueBaseURL: f.server.URL}
+
+	dir, err := ioutil.TempDir("", "taskcluster-upload")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "debug")
+	assert.NoError(ioutil.WriteFile(file, []byte("some debug output\n"), 0644))
+
+	// s3 artifact, expiring with the task
+	assert.True(tk.runUpload(nil, arguments{
+		"<taskId>": "abc", "<runId>": "0", "<file>": file, "--name": "public/debug.log",
+	}))
+	assert.Equal("s3", f.artifacts["public/debug.log"]["storageType"])
+	assert.Equal("2030-01-01T00:00:00Z", f.artifacts["public/debug.log"]["expires"])
+	assert.Equal("some debug output\n", string(f.uploads["public/debug.log"]))
+	assert.Contains(f.types["public/debug.log"], "text/plain")
+
+	// reference artifact
+	assert.True(tk.runUpload(nil, arguments{
+		"<taskId>": "abc", "<runId>": "0", "--name": "public/ref",
+		"--reference": "https://example.com/x", "--expires": "1 year",
+	}))
+	assert.Equal("reference", f.artifacts["public/ref"]["storageType"])
+	assert.Equal("https://example.com/x", f.artifacts["public/ref"]["url"])
+	expires, err := time.Parse(time.RFC3339, f.artifacts["public/ref"]["expires"].(string))
+	assert.NoError(err)
+	assert.True(expires.After(time.Now().AddDate(0, 11, 0)))
+
+	// error artifact
+	assert.True(tk.runUpload(nil, arguments{
+		"<taskId>": "abc", "<runId>": "0", "--name": "public/missing",
+		"--error": "file-missing-on-worker", "--message": "no such file",
+	}))
+	assert.Equal("error", f.artifacts["public/missing"]["storageType"])
+	assert.Equal("no such file", f.artifacts["public/missing"]["message"])
+	assert.False(tk.runUpload(nil, arguments{
+		"<taskId>": "abc", "<runId>": "0", "--name": "public/x",
+		"--error": "bad-reason", "--message": "no such file",
+	}))
+
+	// unknown run
+	assert.False(tk.runUpload(nil, arguments{
+		"<taskId>": "abc", "<runId>": "7", "<file>": file, "--name": "public/debug.log", "--expires": "1 day",
+	}))
+}
+
+func TestDetectContentType(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "taskcluster-upload")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	for name, data := range map[string]string{
+		"index.html":  "",
+		"noextension": "<html><body></body></html>",
+		"empty":       "",
+	} {
+		assert.NoError(ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644))
+	}
+	contentType, err := detectContentType(filepath.Join(dir, "index.html"))
+	assert.NoError(err)
+	assert.Contains(contentType, "text/html")
+	contentType, err = detectContentType(filepath.Join(dir, "noextension"))
+	assert.NoError(err)
+	assert.Contains(contentType, "text/html")
+	contentType, err = detectContentType(filepath.Join(dir, "empty"))
+	assert.NoError(err)
+	assert.Contains(contentType, "text/plain")
+	_, err = detectContentType(filepath.Join(dir, "missing"))
+	assert.Error(err)
+}
@@ -0,0 +1,204 @@
+package task
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	fromNow "github.com/taskcluster/taskcluster-cli/from-now"
+	tcclient "github.com/taskcluster/taskcluster-client-go"
+)
+
+// Exit codes of the wait subcommands, telling how the tasks resolved. Any
+// other error exits with 1.
+const (
+	exitFailed    = 2
+	exitException = 3
+	exitTimeout   = 4
+)
+
+// maxPollInterval is the longest time to wait between polls, when nothing
+// changes.
+const maxPollInterval = time.Minute
+
+// parseDuration parses a time expression like '2 hours', as accepted by
+// from-now.
+func parseDuration(s string) (time.Duration, error) {
+	now := time.Now()
+	t, err := fromNow.Add(now, s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Sub(now), nil
+}
+
+// newWaiter returns a waiter with the --interval and --timeout from args.
+func newWaiter(args arguments) (*waiter, error) {
+	w := &waiter{out: os.Stdout, now: time.Now, sleep: time.Sleep}
+	var err error
+	if w.interval, err = parseDuration(args["--interval"].(string)); err != nil || w.interval <= 0 {
+		return nil, fmt.Errorf("invalid interval: %v", args["--interval"])
+	}
+	if s, ok := args["--timeout"].(string); ok {
+		if w.timeout, err = parseDuration(s); err != nil || w.timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout: %s", s)
+		}
+	}
+	return w, nil
+}
+
+func (t task) runWait(credentials *tcclient.Credentials, args arguments) bool {
+	q := t.queue(credentials)
+	taskIDs := args["<taskIds>"].([]string)
+
+	w, err := newWaiter(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return false
+	}
+	states, timedOut, err := w.wait(func() (map[string]string, error) {
+		states := make(map[string]string, len(taskIDs))
+		for _, taskID := range taskIDs {
+			s, err := q.Status(taskID)
+			if err != nil {
+				return nil, fmt.Errorf("could not get the task %s: %v", taskID, err)
+			}
+			states[taskID] = s.Status.State
+		}
+		return states, nil
+	}, func(states map[string]string) bool {
+		for _, state := range states {
+			if !isResolved(state) {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return false
+	}
+	if code := exitCode(states, timedOut); code != 0 {
+		return t.fail(code)
+	}
+	return true
+}
+
+// isResolved returns true, if a task in state is resolved.
+func isResolved(state string) bool {
+	return state == "completed" || state == "failed" || state == "exception"
+}
+
+// waiter polls the states of tasks until they are done, printing the tasks
+// that change state.
+type waiter struct {
+	// interval is the time between the first polls, it is doubled up to
+	// maxPollInterval while nothing changes
+	interval time.Duration
+	// timeout is the time to give up waiting after, if not zero
+	timeout time.Duration
+	// summarize prints a summary of the states from the first poll, instead
+	// of the state of every task
+	summarize bool
+	out       io.Writer
+	now       func() time.Time
+	sleep     func(time.Duration)
+}
+
+// wait calls poll until done returns true for the states polled or the
+// timeout is reached. It returns the last states and whether it timed out.
+func (w *waiter) wait(
+	poll func() (map[string]string, error), done func(map[string]string) bool,
+) (map[string]string, bool, error) {
+	var deadline time.Time
+	if w.timeout > 0 {
+		deadline = w.now().Add(w.timeout)
+	}
+	var previous map[string]string
+	delay := w.interval
+	for {
+		states, err := poll()
+		if err != nil {
+			return nil, false, err
+		}
+		if w.report(previous, states) {
+			delay = w.interval
+		}
+		previous = states
+		if done(states) {
+			return states, false, nil
+		}
+
+		if !deadline.IsZero() {
+			left := deadline.Sub(w.now())
+			if left <= 0 {
+				fmt.Fprintf(w.out, "Timed out waiting, %s\n", summary(states))
+				return states, true, nil
+			}
+			if delay > left {
+				delay = left
+			}
+		}
+		w.sleep(delay)
+		if delay *= 2; delay > maxPollInterval {
+			delay = maxPollInterval
+		}
+	}
+}
+
+// report prints the tasks that changed state since previous, returning true
+// if any did.
+func (w *waiter) report(previous, states map[string]string) bool {
+	if previous == nil && w.summarize {
+		fmt.Fprintf(w.out, "%s\n", summary(states))
+		return true
+	}
+	var changed []string
+	for taskID, state := range states {
+		if previous[taskID] != state {
+			changed = append(changed, taskID)
+		}
+	}
+	sort.Strings(changed)
+	for _, taskID := range changed {
+		fmt.Fprintf(w.out, "%s %s\n", taskID, states[taskID])
+	}
+	return len(changed) > 0
+}
+
+// summary returns the number of tasks in each state, e.g. '3 tasks: 2
+// completed, 1 running'.
+func summary(states map[string]string) string {
+	counts := make(map[string]int)
+	for _, state := range states {
+		counts[state]++
+	}
+	var parts []string
+	for _, state := range []string{"completed", "failed", "exception", "running", "pending", "unscheduled"} {
+		if counts[state] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[state], state))
+		}
+	}
+	return fmt.Sprintf("%d tasks: %s", len(states), strings.Join(parts, ", "))
+}
+
+// exitCode returns the exit code for tasks resolved in states, a failed task
+// takes precedence over a task resolved as exception.
+func exitCode(states map[string]string, timedOut bool) int {
+	if timedOut {
+		return exitTimeout
+	}
+	code := 0
+	for _, state := range states {
+		switch state {
+		case "failed":
+			return exitFailed
+		case "exception":
+			code = exitException
+		}
+	}
+	return code
+}
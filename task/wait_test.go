@@ -0,0 +1,141 @@
+package task
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestWaiter(t *testing.T) {
+	assert := assert.New(t)
+
+	polls := []map[string]string{
+		{"a": "pending", "b": "pending"},
+		{"a": "pending", "b": "pending"},
+		{"a": "pending", "b": "pending"},
+		{"a": "running", "b": "pending"},
+		{"a": "completed", "b": "running"},
+		{"a": "completed", "b": "failed"},
+	}
+	var out bytes.Buffer
+	var sleeps []time.Duration
+	w := &waiter{
+		interval: 10 * time.Second,
+		out:      &out,
+		now:      time.Now,
+		sleep:    func(d time.Duration) { sleeps = append(sleeps, d) },
+	}
+	poll := func() (map[string]string, error) {
+		states := polls[0]
+		polls = polls[1:]
+		return states, nil
+	}
+	resolved := func(states map[string]string) bool {
+		for _, state := range states {
+			if !isResolved(state) {
+				return false
+			}
+		}
+		return true
+	}
+
+	states, timedOut, err := w.wait(poll, resolved)
+	assert.NoError(err)
+	assert.False(timedOut)
+	assert.Equal(map[string]string{"a": "completed", "b": "failed"}, states)
+	assert.Equal("a pending\nb pending\na running\na completed\nb running\nb failed\n", out.String())
+	// Backing off while nothing changes, and back to the interval on changes
+	assert.Equal([]time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, 10 * time.Second, 10 * time.Second}, sleeps)
+
+	_, _, err = w.wait(func() (map[string]string, error) {
+		return nil, errors.New("queue is down")
+	}, resolved)
+	assert.Error(err)
+}
+
+func TestWaiterTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	var out bytes.Buffer
+	w := &waiter{
+		interval:  time.Minute,
+		timeout:   90 * time.Second,
+		summarize: true,
+		out:       &out,
+		now:       func() time.Time { return now },
+		sleep:     func(d time.Duration) { now = now.Add(d) },
+	}
+	states, timedOut, err := w.wait(func() (map[string]string, error) {
+		return map[string]string{"a": "running", "b": "completed", "c": "running"}, nil
+	}, func(map[string]string) bool { return false })
+	assert.NoError(err)
+	assert.True(timedOut)
+	assert.Len(states, 3)
+	assert.Equal("3 tasks: 1 completed, 2 running\nTimed out waiting, 3 tasks: 1 completed, 2 running\n", out.String())
+	assert.Equal(exitTimeout, exitCode(states, timedOut))
+}
+
+func TestExitCode(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(0, exitCode(map[string]string{"a": "completed", "b": "completed"}, false))
+	assert.Equal(exitException, exitCode(map[string]string{"a": "completed", "b": "exception"}, false))
+	assert.Equal(exitFailed, exitCode(map[string]string{"a": "exception", "b": "failed"}, false))
+	assert.Equal(exitTimeout, exitCode(map[string]string{"a": "completed"}, true))
+}
+
+// failRecorder returns a fail function for a task, which records the exit
+// code in code.
+func failRecorder(code *int) func(int) bool {
+	return func(c int) bool {
+		*code = c
+		return false
+	}
+}
+
+func TestWait(t *testing.T) {
+	assert := assert.New(t)
+
+	states := map[string]string{"a": "completed", "b": "exception", "c": "failed"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		taskID := strings.Split(r.URL.Path, "/")[2]
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": map[string]interface{}{"taskId": taskID, "state": states[taskID]},
+		})
+	}))
+	defer server.Close()
+
+	code := 0
+	tk := task{queueBaseURL: server.URL, fail: failRecorder(&code)}
+	wait := func(taskIDs ...string) bool {
+		return tk.runWait(nil, arguments{"<taskIds>": taskIDs, "--interval": "1 second"})
+	}
+
+	assert.True(wait("a"))
+	assert.Equal(0, code)
+	assert.False(wait("a", "b"))
+	assert.Equal(exitException, code)
+	assert.False(wait("a", "b", "c"))
+	assert.Equal(exitFailed, code)
+}
+
+func TestParseDuration(t *testing.T) {
+	assert := assert.New(t)
+
+	d, err := parseDuration("5 seconds")
+	assert.NoError(err)
+	assert.Equal(5*time.Second, d)
+	d, err = parseDuration("2 hours")
+	assert.NoError(err)
+	assert.Equal(2*time.Hour, d)
+	_, err = parseDuration("soon")
+	assert.Error(err)
+}
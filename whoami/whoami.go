@@ -0,0 +1,184 @@
+package whoami
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/taskcluster/taskcluster-cli/client"
+	"github.com/taskcluster/taskcluster-cli/config"
+	"github.com/taskcluster/taskcluster-cli/extpoints"
+	"github.com/taskcluster/taskcluster-cli/retry"
+	"github.com/taskcluster/taskcluster-cli/scopes"
+)
+
+func init() {
+	extpoints.Register("whoami", whoami{})
+}
+
+type whoami struct{}
+
+func (whoami) ConfigOptions() map[string]extpoints.ConfigOption {
+	return nil
+}
+
+func (whoami) Summary() string {
+	return "Show the configured credentials."
+}
+
+func (whoami) UsesCredentials() bool {
+	return true
+}
+
+func (whoami) Usage() string {
+	return `Show the configured credentials, whether they are permanent or temporary,
+and for temporary credentials who issued them, when they expire and the scopes
+of their certificate.
+
+Usage:
+  taskcluster whoami [--verify]
+
+Options:
+  --verify  Fetch the current scopes of the credentials from the auth service
+
+With --verify, the current scopes are compared with the authorized scopes or
+the certificate scopes, and whoami exits non-zero if any of them are missing.
+Current scopes beyond them, as expanded from assume: scopes, are only listed.
+`
+}
+
+func (whoami) Execute(context extpoints.Context) bool {
+	creds := context.Credentials
+	if creds == nil {
+		fmt.Fprintln(os.Stderr, "No credentials configured, use 'taskcluster signin' or set")
+		fmt.Fprintln(os.Stderr, "TASKCLUSTER_CLIENT_ID and TASKCLUSTER_ACCESS_TOKEN.")
+		return false
+	}
+	cert, err := creds.ParseCertificate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid credentials, error: %s\n", err)
+		return false
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 1, ' ', 0)
+	fmt.Fprintf(w, "ClientId:\t%s\n", creds.ClientID)
+	if cert == nil {
+		fmt.Fprintf(w, "Type:\tpermanent\n")
+	} else {
+		fmt.Fprintf(w, "Type:\ttemporary\n")
+		if cert.Issuer != "" {
+			fmt.Fprintf(w, "Issuer:\t%s\n", cert.Issuer)
+		}
+		fmt.Fprintf(w, "Start:\t%s\n", cert.StartTime().Format(time.RFC3339))
+		fmt.Fprintf(w, "Expiry:\t%s (%s)\n", cert.ExpiryTime().Format(time.RFC3339), remaining(cert.ExpiryTime(), time.Now()))
+	}
+	w.Flush()
+
+	if len(creds.AuthorizedScopes) > 0 {
+		printScopes("Authorized scopes:", creds.AuthorizedScopes)
+	}
+	if cert != nil {
+		printScopes("Certificate scopes:", cert.Scopes)
+	}
+
+	if context.Arguments["--verify"] == true {
+		current, err := currentScopes(context)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to verify credentials, error: %s\n", err)
+			return false
+		}
+		printScopes("Current scopes (from auth service):", current)
+
+		// Compare with the scopes the credentials are expected to have,
+		// permanent credentials have no scopes configured to compare with
+		expected := creds.AuthorizedScopes
+		if len(expected) == 0 && cert != nil {
+			expected = cert.Scopes
+		}
+		if len(expected) == 0 {
+			return true
+		}
+		lost, gained := scopeDiff(expected, current)
+		if len(lost) > 0 {
+			printScopes("Scopes missing from the current scopes:", lost)
+		}
+		if len(gained) > 0 {
+			printScopes("Current scopes not in the configured scopes:", gained)
+		}
+		if len(lost) > 0 {
+			fmt.Fprintln(os.Stderr, "The current scopes of the credentials are missing configured scopes")
+			return false
+		}
+	}
+	return true
+}
+
+// scopeDiff returns the expected scopes not satisfied by the current scopes,
+// and the current scopes not satisfied by the expected scopes.
+func scopeDiff(expected, current []string) (lost, gained []string) {
+	return scopes.Missing(current, expected), scopes.Missing(expected, current)
+}
+
+// remaining describes the time left until expiry.
+func remaining(expiry, now time.Time) string {
+	if !expiry.After(now) {
+		return fmt.Sprintf("expired %s ago", now.Sub(expiry)/time.Second*time.Second)
+	}
+	return fmt.Sprintf("expires in %s", expiry.Sub(now)/time.Second*time.Second)
+}
+
+func printScopes(title string, scopes []string) {
+	fmt.Println(title)
+	if len(scopes) == 0 {
+		fmt.Println("  (none)")
+	}
+	for _, scope := range scopes {
+		fmt.Printf("  %s\n", scope)
+	}
+}
+
+// authBaseURL returns the base URL of the auth service, which is the base URL
+// configured for the auth command, or derived from the root URL.
+func authBaseURL(context extpoints.Context) string {
+	if baseURL, ok := config.Configuration["auth"]["baseUrl"].(string); ok && baseURL != "" {
+		return baseURL
+	}
+	return client.ServiceURL(context.RootURL, "auth", "v1")
+}
+
+// currentScopes fetches the scopes of the credentials from the auth service.
+func currentScopes(context extpoints.Context) ([]string, error) {
+	req, err := http.NewRequest("GET", authBaseURL(context)+"/scopes/current", nil)
+	if err != nil {
+		return nil, err
+	}
+	c := retry.NewClient(retry.DefaultOptions, func(req *http.Request, body []byte) error {
+		return context.Credentials.SignRequest(req, nil)
+	})
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Scopes  []string `json:"scopes"`
+		Message string   `json:"message"`
+	}
+	json.Unmarshal(data, &result)
+	if res.StatusCode/100 != 2 {
+		if result.Message == "" {
+			result.Message = string(data)
+		}
+		return nil, fmt.Errorf("auth service responded with status %d: %s", res.StatusCode, result.Message)
+	}
+	return result.Scopes, nil
+}
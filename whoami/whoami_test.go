@@ -0,0 +1,65 @@
+package whoami
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster-cli/client"
+	"github.com/taskcluster/taskcluster-cli/config"
+	"github.com/taskcluster/taskcluster-cli/extpoints"
+)
+
+func TestRemaining(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Unix(1500000000, 0)
+	assert.Equal("expires in 1h30m0s", remaining(now.Add(90*time.Minute+300*time.Millisecond), now))
+	assert.Equal("expired 2m0s ago", remaining(now.Add(-2*time.Minute), now))
+}
+
+func TestVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	current := []string{"queue:create-task:*"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/scopes/current", r.URL.Path)
+		json.NewEncoder(w).Encode(map[string]interface{}{"scopes": current})
+	}))
+	defer server.Close()
+	config.Configuration = map[string]map[string]interface{}{"auth": {"baseUrl": server.URL}}
+	defer func() { config.Configuration = nil }()
+
+	verify := func(authorized ...string) bool {
+		return whoami{}.Execute(extpoints.Context{
+			Arguments: map[string]interface{}{"--verify": true},
+			Credentials: &client.Credentials{
+				ClientID:         "tester",
+				AccessToken:      "secret",
+				AuthorizedScopes: authorized,
+			},
+		})
+	}
+
+	assert.True(verify("queue:create-task:*"))
+	assert.True(verify())
+	assert.False(verify("queue:create-task:*", "queue:cancel-task:*"))
+	current = []string{"queue:create-task:*", "auth:*"}
+	assert.True(verify("queue:create-task:*"))
+
+	// The auth service expands assume: scopes into the scopes of the roles
+	current = []string{"assume:project:ci", "queue:create-task:*", "queue:route:index.ci.*"}
+	assert.True(verify("assume:project:ci"))
+	assert.False(verify("assume:project:ci", "auth:create-client:*"))
+}
+
+func TestScopeDiff(t *testing.T) {
+	assert := assert.New(t)
+
+	lost, gained := scopeDiff([]string{"a:1", "b:*"}, []string{"a:*", "c"})
+	assert.Equal([]string{"b:*"}, lost)
+	assert.Equal([]string{"a:*", "c"}, gained)
+}